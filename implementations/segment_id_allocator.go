@@ -0,0 +1,259 @@
+package implementations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrInvalidSegmentSize is returned when a segment of fewer than one ID is leased.
+	ErrInvalidSegmentSize = errors.New("segment size must be positive")
+	// ErrAllocatorClosed is returned by Next after the allocator is closed.
+	ErrAllocatorClosed = errors.New("segment allocator closed")
+)
+
+// Segment is a leased range of IDs, from Start up to but excluding End.
+type Segment struct {
+	Start int64
+	End   int64
+}
+
+// SegmentStore hands out non-overlapping ranges of IDs.
+type SegmentStore interface {
+	// Lease reserves the next size IDs for key.
+	Lease(key string, size int64) (Segment, error)
+}
+
+// MemorySegmentStore is a SegmentStore that keeps its state in memory.
+// It is only unique within a single process.
+type MemorySegmentStore struct {
+	mu   sync.Mutex
+	next map[string]int64
+}
+
+// NewMemorySegmentStore creates a new in-memory segment store.
+func NewMemorySegmentStore() *MemorySegmentStore {
+	return &MemorySegmentStore{next: make(map[string]int64)}
+}
+
+// Lease reserves the next size IDs for key.
+func (m *MemorySegmentStore) Lease(key string, size int64) (Segment, error) {
+	if size <= 0 {
+		return Segment{}, ErrInvalidSegmentSize
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seg := Segment{Start: m.next[key], End: m.next[key] + size}
+	m.next[key] = seg.End
+	return seg, nil
+}
+
+// FileSegmentStore is a SegmentStore that durably records the next free ID of
+// every key in a file, so leases survive restarts.
+// The file must not be shared by multiple processes at the same time.
+type FileSegmentStore struct {
+	path string
+	mu   sync.Mutex
+	next map[string]int64
+}
+
+// NewFileSegmentStore opens the segment store at path, creating it on the first
+// lease if it doesn't exist.
+func NewFileSegmentStore(path string) (*FileSegmentStore, error) {
+	next := make(map[string]int64)
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("reading segment store: %w", err)
+	default:
+		if err := json.Unmarshal(data, &next); err != nil {
+			return nil, fmt.Errorf("parsing segment store %q: %w", path, err)
+		}
+	}
+
+	return &FileSegmentStore{path: path, next: next}, nil
+}
+
+// Lease reserves the next size IDs for key. The lease is synced to disk before
+// it is returned.
+func (f *FileSegmentStore) Lease(key string, size int64) (Segment, error) {
+	if size <= 0 {
+		return Segment{}, ErrInvalidSegmentSize
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	seg := Segment{Start: f.next[key], End: f.next[key] + size}
+	f.next[key] = seg.End
+	data, err := json.Marshal(f.next)
+	if err == nil {
+		err = writeFileSync(f.path, data)
+	}
+	if err != nil {
+		f.next[key] = seg.Start
+		return Segment{}, fmt.Errorf("persisting segment store: %w", err)
+	}
+	return seg, nil
+}
+
+// SegmentAllocator issues IDs from segments leased from a SegmentStore. It
+// double-buffers: once the current segment is partly consumed, the next one is
+// leased in the background so Next rarely waits on the store.
+//
+// Call Close when done with the allocator, so that a background lease doesn't
+// outlive it and race with whoever uses the store next.
+type SegmentAllocator struct {
+	key       string
+	store     SegmentStore
+	size      int64
+	threshold int64 // IDs consumed from the current segment before prefetching.
+
+	mu       sync.Mutex // Protects the following variables
+	cur      Segment    // Current segment; cur.Start is the next ID to issue
+	consumed int64      // IDs issued from the current segment
+	buffered *Segment   // Prefetched segment, if any
+	loading  chan struct{}
+	loadErr  error
+	closed   bool
+}
+
+// SegmentAllocatorConfig is needed to create a new segment allocator.
+type SegmentAllocatorConfig struct {
+	// SegmentSize is the number of IDs leased from the store at a time.
+	// Default is 1000.
+	SegmentSize int64
+	// PrefetchRatio is the fraction of the current segment that must be
+	// consumed before the next segment is leased.
+	// Default is 0.1.
+	PrefetchRatio float64
+}
+
+// SegmentAllocatorOption is used to configure a new segment allocator.
+type SegmentAllocatorOption func(*SegmentAllocatorConfig)
+
+// WithSegmentSize sets the number of IDs leased from the store at a time.
+func WithSegmentSize(size int64) SegmentAllocatorOption {
+	return func(c *SegmentAllocatorConfig) {
+		c.SegmentSize = size
+	}
+}
+
+// WithPrefetchRatio sets the fraction of the current segment that must be
+// consumed before the next segment is leased.
+func WithPrefetchRatio(ratio float64) SegmentAllocatorOption {
+	return func(c *SegmentAllocatorConfig) {
+		c.PrefetchRatio = ratio
+	}
+}
+
+// NewSegmentAllocator creates a new segment allocator issuing IDs for key.
+func NewSegmentAllocator(store SegmentStore, key string, opts ...SegmentAllocatorOption) *SegmentAllocator {
+	const (
+		defaultSegmentSize   = 1000
+		defaultPrefetchRatio = 0.1
+	)
+	c := &SegmentAllocatorConfig{
+		SegmentSize:   defaultSegmentSize,
+		PrefetchRatio: defaultPrefetchRatio,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return &SegmentAllocator{
+		key:       key,
+		store:     store,
+		size:      c.SegmentSize,
+		threshold: int64(float64(c.SegmentSize) * c.PrefetchRatio),
+	}
+}
+
+// Next returns a new unique ID.
+func (a *SegmentAllocator) Next() (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for {
+		if a.closed {
+			return 0, ErrAllocatorClosed
+		}
+		if a.cur.Start < a.cur.End {
+			id := a.cur.Start
+			a.cur.Start++
+			a.consumed++
+			if a.consumed >= a.threshold && a.buffered == nil && a.loading == nil {
+				a.startLeaseLocked()
+			}
+			return id, nil
+		}
+
+		// The current segment is exhausted, switch to the buffered one.
+		if a.buffered != nil {
+			a.cur, a.buffered, a.consumed = *a.buffered, nil, 0
+			continue
+		}
+
+		if a.loading == nil {
+			if err := a.loadErr; err != nil {
+				// Report a failed prefetch once, then retry on the next call.
+				a.loadErr = nil
+				return 0, err
+			}
+			a.startLeaseLocked()
+		}
+
+		loading := a.loading
+		a.mu.Unlock()
+		<-loading
+		a.mu.Lock()
+
+		if a.buffered == nil && a.loadErr != nil {
+			err := a.loadErr
+			a.loadErr = nil
+			return 0, err
+		}
+	}
+}
+
+// Close stops the allocator and waits for a background lease to finish. IDs
+// left in leased segments are never issued. Next returns ErrAllocatorClosed
+// afterwards.
+func (a *SegmentAllocator) Close() {
+	a.mu.Lock()
+	a.closed = true
+	loading := a.loading
+	a.mu.Unlock()
+
+	if loading != nil {
+		<-loading
+	}
+}
+
+// startLeaseLocked leases the next segment in the background. The lease is
+// owned by the allocator: Close waits for it.
+func (a *SegmentAllocator) startLeaseLocked() {
+	done := make(chan struct{})
+	a.loading = done
+	a.loadErr = nil
+
+	go func() {
+		seg, err := a.store.Lease(a.key, a.size)
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if err != nil {
+			a.loadErr = fmt.Errorf("leasing segment for %q: %w", a.key, err)
+		} else {
+			a.buffered = &seg
+		}
+		a.loading = nil
+		close(done)
+	}()
+}
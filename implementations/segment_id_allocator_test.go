@@ -0,0 +1,210 @@
+package implementations
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+var (
+	_ IDSource = (*IDGenerator)(nil)
+	_ IDSource = (*SegmentAllocator)(nil)
+)
+
+// recordingStore wraps a SegmentStore and reports every lease.
+type recordingStore struct {
+	SegmentStore
+	leases chan Segment
+	err    error
+}
+
+func (r *recordingStore) Lease(key string, size int64) (Segment, error) {
+	if r.err != nil {
+		return Segment{}, r.err
+	}
+	seg, err := r.SegmentStore.Lease(key, size)
+	r.leases <- seg
+	return seg, err
+}
+
+func TestSegmentAllocatorUniqueConcurrent(t *testing.T) {
+	const (
+		numAllocators = 4
+		numWorkers    = 8
+		idsPerWorker  = 2_000
+	)
+
+	// Allocators sharing a store stand in for several service instances.
+	store := NewMemorySegmentStore()
+	allocators := make([]*SegmentAllocator, numAllocators)
+	for i := range allocators {
+		allocators[i] = NewSegmentAllocator(store, "orders", WithSegmentSize(100))
+	}
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]struct{})
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(a *SegmentAllocator) {
+			defer wg.Done()
+			for i := 0; i < idsPerWorker; i++ {
+				id, err := a.Next()
+				if err != nil {
+					t.Errorf("Next failed: %v", err)
+					return
+				}
+				mu.Lock()
+				if _, ok := seen[id]; ok {
+					t.Errorf("duplicate ID %d", id)
+				}
+				seen[id] = struct{}{}
+				mu.Unlock()
+			}
+		}(allocators[w%numAllocators])
+	}
+	wg.Wait()
+}
+
+func TestSegmentAllocatorIncreasing(t *testing.T) {
+	a := NewSegmentAllocator(NewMemorySegmentStore(), "orders", WithSegmentSize(10))
+	for want := int64(0); want < 100; want++ {
+		got, err := a.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if got != want {
+			t.Fatalf("Next: got %d, want %d", got, want)
+		}
+	}
+}
+
+func TestSegmentAllocatorPrefetch(t *testing.T) {
+	store := &recordingStore{SegmentStore: NewMemorySegmentStore(), leases: make(chan Segment, 10)}
+	a := NewSegmentAllocator(store, "orders", WithSegmentSize(100))
+
+	for i := 0; i < 9; i++ {
+		if _, err := a.Next(); err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+	}
+	if got := <-store.leases; got != (Segment{0, 100}) {
+		t.Fatalf("first lease: got %v, want %v", got, Segment{0, 100})
+	}
+	select {
+	case seg := <-store.leases:
+		t.Fatalf("segment %v prefetched before 10%% was consumed", seg)
+	default:
+	}
+
+	// The tenth ID crosses the threshold.
+	if _, err := a.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if got := <-store.leases; got != (Segment{100, 200}) {
+		t.Fatalf("prefetched lease: got %v, want %v", got, Segment{100, 200})
+	}
+
+	// Draining the current segment switches to the buffered one without
+	// another lease until the threshold is crossed again.
+	for want := int64(10); want < 110; want++ {
+		got, err := a.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if got != want {
+			t.Fatalf("Next: got %d, want %d", got, want)
+		}
+	}
+	if got := <-store.leases; got != (Segment{200, 300}) {
+		t.Fatalf("second prefetched lease: got %v, want %v", got, Segment{200, 300})
+	}
+}
+
+func TestSegmentAllocatorStoreError(t *testing.T) {
+	errStore := errors.New("store unavailable")
+	store := &recordingStore{SegmentStore: NewMemorySegmentStore(), leases: make(chan Segment, 10), err: errStore}
+	a := NewSegmentAllocator(store, "orders", WithSegmentSize(10))
+
+	if _, err := a.Next(); !errors.Is(err, errStore) {
+		t.Fatalf("Next: got %v, want %v", err, errStore)
+	}
+
+	// The allocator recovers once the store does.
+	store.err = nil
+	if got, err := a.Next(); err != nil || got != 0 {
+		t.Errorf("Next after recovery: got %d, %v, want 0, nil", got, err)
+	}
+}
+
+func TestSegmentAllocatorClose(t *testing.T) {
+	store := &recordingStore{SegmentStore: NewMemorySegmentStore(), leases: make(chan Segment, 2)}
+	a := NewSegmentAllocator(store, "orders", WithSegmentSize(10), WithPrefetchRatio(0.1))
+	if _, err := a.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+
+	// The first ID leases a segment and starts prefetching the next, which has
+	// finished once Close returns.
+	a.Close()
+	if n := len(store.leases); n != 2 {
+		t.Errorf("leases after Close: got %d, want 2", n)
+	}
+	if _, err := a.Next(); !errors.Is(err, ErrAllocatorClosed) {
+		t.Errorf("Next after Close: got %v, want %v", err, ErrAllocatorClosed)
+	}
+}
+
+func TestFileSegmentStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "segments.json")
+
+	store, err := NewFileSegmentStore(path)
+	if err != nil {
+		t.Fatalf("NewFileSegmentStore failed: %v", err)
+	}
+	a := NewSegmentAllocator(store, "orders", WithSegmentSize(50))
+	var last int64
+	for i := 0; i < 20; i++ {
+		if last, err = a.Next(); err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+	}
+	if _, err := store.Lease("users", 5); err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	a.Close()
+
+	// A new process reopens the store; unused IDs in leased segments are
+	// skipped rather than reissued.
+	reopened, err := NewFileSegmentStore(path)
+	if err != nil {
+		t.Fatalf("reopening store: %v", err)
+	}
+	b := NewSegmentAllocator(reopened, "orders", WithSegmentSize(50))
+	defer b.Close()
+	id, err := b.Next()
+	if err != nil {
+		t.Fatalf("Next after restart failed: %v", err)
+	}
+	if id <= last {
+		t.Errorf("ID after restart %d not above last issued %d", id, last)
+	}
+	if seg, err := reopened.Lease("users", 5); err != nil || seg != (Segment{5, 10}) {
+		t.Errorf("Lease for second key: got %v, %v, want %v", seg, err, Segment{5, 10})
+	}
+}
+
+func TestSegmentStoreInvalidSize(t *testing.T) {
+	file, err := NewFileSegmentStore(filepath.Join(t.TempDir(), "segments.json"))
+	if err != nil {
+		t.Fatalf("NewFileSegmentStore failed: %v", err)
+	}
+	for _, store := range []SegmentStore{NewMemorySegmentStore(), file} {
+		if _, err := store.Lease("orders", 0); !errors.Is(err, ErrInvalidSegmentSize) {
+			t.Errorf("%T.Lease(0): got %v, want %v", store, err, ErrInvalidSegmentSize)
+		}
+	}
+}
@@ -1,50 +1,326 @@
 package implementations
 
 import (
+	"errors"
+	"fmt"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// IDs use a snowflake-style layout: the millisecond timestamp in the high bits,
+// followed by the node ID and the per-millisecond step.
 const (
 	nodeBits = 10
 	stepBits = 12
+	maxNode  = 1<<nodeBits - 1
 	maxStep  = 1<<stepBits - 1
+	tsShift  = nodeBits + stepBits
 )
 
 var (
-	mutex  sync.Mutex // Protects the following variables
+	// ErrClockMovedBackwards is returned when the clock reads earlier than the
+	// timestamp of the last issued ID.
+	ErrClockMovedBackwards = errors.New("clock moved backwards")
+	// ErrStateMarkAhead is returned after a restart while the clock reads
+	// earlier than the high-water mark recorded in the state file, since IDs
+	// below the mark may already have been issued.
+	ErrStateMarkAhead = errors.New("clock behind persisted high-water mark")
+	// ErrInvalidBatchSize is returned when NextN is asked for fewer than one ID.
+	ErrInvalidBatchSize = errors.New("batch size must be positive")
+)
+
+// IDSource issues unique 64-bit IDs.
+type IDSource interface {
+	// Next returns a new unique ID.
+	Next() (int64, error)
+}
+
+// IDGenerator generates unique, roughly time-ordered 64-bit IDs.
+type IDGenerator struct {
+	mu     sync.Mutex // Protects the following variables
 	lastTs int64      // Timestamp of last ID generation
 	step   int64      // Counter for IDs generated in the same millisecond
-)
 
-func generateID() int64 {
-	mutex.Lock()
-	defer mutex.Unlock()
+	// nodeID is resolved once at construction so generating an ID never
+	// has to touch the network interfaces.
+	nodeID int64
+	clock  func() time.Time
+	// hwm is nil unless the generator persists its high-water mark.
+	hwm *highWaterMark
+}
+
+// IDGeneratorConfig is needed to create a new ID generator.
+type IDGeneratorConfig struct {
+	// NodeID identifies this generator among all generators issuing IDs.
+	// Only the low nodeBits bits are used.
+	// Default is derived from the MAC address, or random if none is available.
+	NodeID int64
+	// Clock returns the current time.
+	// Default is time.Now.
+	Clock func() time.Time
+	// StatePath is a file in which the generator durably records a high-water
+	// mark for its timestamps, so a restarted generator never reissues IDs.
+	// Default is no state file.
+	StatePath string
+	// StateInterval is how far ahead of the current timestamp the high-water
+	// mark is recorded. A larger interval means fewer writes but a longer
+	// window after a restart in which Next returns ErrStateMarkAhead, until
+	// the clock reaches the mark.
+	// Default is 1 second.
+	StateInterval time.Duration
+
+	nodeIDSet bool
+}
+
+// IDGeneratorOption is used to configure a new ID generator.
+type IDGeneratorOption func(*IDGeneratorConfig)
+
+// WithNodeID sets the node ID embedded in every generated ID.
+func WithNodeID(id int64) IDGeneratorOption {
+	return func(c *IDGeneratorConfig) {
+		c.NodeID = id
+		c.nodeIDSet = true
+	}
+}
+
+// WithClock sets the clock used to timestamp IDs.
+func WithClock(clock func() time.Time) IDGeneratorOption {
+	return func(c *IDGeneratorConfig) {
+		c.Clock = clock
+	}
+}
+
+// WithStateFile persists the generator's high-water mark to path, recording it
+// interval ahead of the current timestamp.
+func WithStateFile(path string, interval time.Duration) IDGeneratorOption {
+	return func(c *IDGeneratorConfig) {
+		c.StatePath = path
+		c.StateInterval = interval
+	}
+}
+
+// NewIDGenerator creates a new ID generator.
+func NewIDGenerator(opts ...IDGeneratorOption) *IDGenerator {
+	c := &IDGeneratorConfig{Clock: time.Now}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if !c.nodeIDSet {
+		c.NodeID = getNodeID()
+	}
+
+	g := &IDGenerator{
+		nodeID: c.NodeID & maxNode,
+		clock:  c.Clock,
+		lastTs: -1,
+	}
+	if c.StatePath != "" {
+		const defaultStateInterval = time.Second
+		interval := c.StateInterval
+		if interval <= 0 {
+			interval = defaultStateInterval
+		}
+		g.hwm = &highWaterMark{path: c.StatePath, interval: max(interval.Milliseconds(), 1)}
+	}
+	return g
+}
+
+// Next returns a new unique ID.
+func (g *IDGenerator) Next() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ts, err := g.currentMilliLocked()
+	if err != nil {
+		return 0, err
+	}
+	return g.nextLocked(ts)
+}
+
+// NextN reserves n unique IDs under a single lock acquisition and clock read.
+// The returned IDs are strictly increasing.
+func (g *IDGenerator) NextN(n int) ([]int64, error) {
+	if n <= 0 {
+		return nil, ErrInvalidBatchSize
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ts, err := g.currentMilliLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, n)
+	for i := range ids {
+		if ids[i], err = g.nextLocked(ts); err != nil {
+			return nil, err
+		}
+		ts = g.lastTs
+	}
+	return ids, nil
+}
+
+// currentMilliLocked reads the clock and rejects readings earlier than the last
+// issued ID, which would otherwise allow duplicates.
+func (g *IDGenerator) currentMilliLocked() (int64, error) {
+	if g.hwm != nil && !g.hwm.loaded {
+		if err := g.hwm.load(); err != nil {
+			return 0, err
+		}
+		// IDs may have been issued at any timestamp below the mark, so act as
+		// if the last one used up every step of the millisecond before it.
+		if g.hwm.mark-1 > g.lastTs {
+			g.lastTs = g.hwm.mark - 1
+			g.step = maxStep
+			g.hwm.restored = true
+		}
+	}
 
-	ts := time.Now().UnixMilli()
+	ts := g.clock().UnixMilli()
+	if ts < g.lastTs {
+		// Until an ID is issued past the mark, lastTs is the mark itself
+		// rather than a timestamp this generator handed out.
+		if g.hwm != nil && g.hwm.restored && g.lastTs == g.hwm.mark-1 {
+			return 0, ErrStateMarkAhead
+		}
+		return 0, ErrClockMovedBackwards
+	}
+	return ts, nil
+}
 
-	// if the time in millisecond is the same as last time, and we haven't exhausted the step
-	// in this millisecond, increase the step.
-	if ts == lastTs && step < maxStep {
-		step++
-	} else {
-		step = 0
-		lastTs = ts
+// nextLocked issues the next ID given a timestamp no earlier than lastTs.
+func (g *IDGenerator) nextLocked(ts int64) (int64, error) {
+	var step int64
+	switch {
+	case ts > g.lastTs:
+	case g.step < maxStep:
+		// Same millisecond as last time and we haven't exhausted the step.
+		step = g.step + 1
+	default:
+		// The step is exhausted, wait until the next millisecond.
+		ts = g.waitNextMilli()
 	}
 
-	res := ts << (nodeBits + stepBits)
+	if g.hwm != nil {
+		if err := g.hwm.reserve(ts); err != nil {
+			return 0, err
+		}
+	}
+	g.lastTs, g.step = ts, step
 
-	// NodeID use the MAC address.
-	// If the MAC address is not available, use a random number.
-	nodeID := getNodeID()
-	res |= nodeID << stepBits
+	return ts<<tsShift | g.nodeID<<stepBits | step, nil
+}
+
+// IDParts are the fields packed into an ID issued by an IDGenerator.
+type IDParts struct {
+	Timestamp time.Time
+	NodeID    int64
+	Step      int64
+}
+
+// DecomposeID unpacks an ID issued by an IDGenerator.
+func DecomposeID(id int64) IDParts {
+	return IDParts{
+		Timestamp: time.UnixMilli(id >> tsShift),
+		NodeID:    id >> stepBits & maxNode,
+		Step:      id & maxStep,
+	}
+}
+
+func (g *IDGenerator) waitNextMilli() int64 {
+	ts := g.clock().UnixMilli()
+	for ts <= g.lastTs {
+		runtime.Gosched()
+		ts = g.clock().UnixMilli()
+	}
+	return ts
+}
+
+// highWaterMark durably records a timestamp that every issued ID is below.
+type highWaterMark struct {
+	path     string
+	interval int64 // Milliseconds to reserve ahead of the current timestamp.
+	mark     int64 // Last persisted mark.
+	loaded   bool
+	restored bool // Whether lastTs was raised to the loaded mark.
+}
 
-	// Step is the number of IDs generated in the same millisecond.
-	// If the step is greater than the maximum value, wait until the next millisecond.
-	step := rand.Int63n(maxStep)
-	return res | step
+// load reads the persisted mark. A missing file means no IDs were issued yet.
+func (h *highWaterMark) load() error {
+	data, err := os.ReadFile(h.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("reading id generator state: %w", err)
+	default:
+		mark, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("parsing id generator state %q: %w", h.path, err)
+		}
+		h.mark = mark
+	}
+	h.loaded = true
+	return nil
+}
+
+// reserve makes sure ts is below the persisted mark, advancing and syncing the
+// mark to disk before any ID at or above it is issued.
+func (h *highWaterMark) reserve(ts int64) error {
+	if ts < h.mark {
+		return nil
+	}
+
+	mark := ts + h.interval
+	if err := writeFileSync(h.path, []byte(strconv.FormatInt(mark, 10)+"\n")); err != nil {
+		return fmt.Errorf("persisting id generator state: %w", err)
+	}
+	h.mark = mark
+	return nil
+}
+
+// writeFileSync atomically replaces path with data. The data is written to a
+// uniquely named temporary file and synced before it is renamed into place, so
+// a crash leaves either the old or the new contents, and concurrent writers
+// never share a temporary file.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err = f.Write(data); err == nil {
+		err = f.Chmod(0o644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Sync the directory so the rename itself survives a crash.
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
 }
 
 // getNodeID returns the MAC address of the machine if available, otherwise
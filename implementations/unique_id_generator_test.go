@@ -0,0 +1,363 @@
+package implementations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIDGeneratorUniqueConcurrent(t *testing.T) {
+	const (
+		numWorkers   = 16
+		idsPerWorker = 5_000
+	)
+
+	gen := NewIDGenerator(WithNodeID(7))
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]struct{}, numWorkers*idsPerWorker)
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			ids := make([]int64, 0, idsPerWorker)
+			for len(ids) < idsPerWorker {
+				// Mix single and batch reservations.
+				if w%2 == 0 {
+					id, err := gen.Next()
+					if err != nil {
+						t.Errorf("Next failed: %v", err)
+						return
+					}
+					ids = append(ids, id)
+					continue
+				}
+				batch, err := gen.NextN(100)
+				if err != nil {
+					t.Errorf("NextN failed: %v", err)
+					return
+				}
+				ids = append(ids, batch...)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, id := range ids {
+				if _, ok := seen[id]; ok {
+					t.Errorf("duplicate ID %d", id)
+				}
+				seen[id] = struct{}{}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestIDGeneratorNextNIncreasing(t *testing.T) {
+	gen := NewIDGenerator(WithNodeID(1))
+
+	// Larger than a single millisecond's worth of steps.
+	ids, err := gen.NextN(3 * (maxStep + 1))
+	if err != nil {
+		t.Fatalf("NextN failed: %v", err)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("IDs not increasing at %d: %d <= %d", i, ids[i], ids[i-1])
+		}
+	}
+
+	next, err := gen.Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if next <= ids[len(ids)-1] {
+		t.Errorf("Next after NextN not increasing: %d <= %d", next, ids[len(ids)-1])
+	}
+}
+
+func TestIDGeneratorNextNInvalidSize(t *testing.T) {
+	gen := NewIDGenerator(WithNodeID(1))
+	for _, n := range []int{0, -1} {
+		if _, err := gen.NextN(n); !errors.Is(err, ErrInvalidBatchSize) {
+			t.Errorf("NextN(%d): got %v, want %v", n, err, ErrInvalidBatchSize)
+		}
+	}
+}
+
+func TestIDGeneratorNodeID(t *testing.T) {
+	gen := NewIDGenerator(WithNodeID(maxNode + 5))
+	id, err := gen.Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if got := id >> stepBits & maxNode; got != 4 {
+		t.Errorf("node bits: got %d, want 4", got)
+	}
+}
+
+func TestIDGeneratorClockMovedBackwards(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_000)
+	gen := NewIDGenerator(WithNodeID(1), WithClock(func() time.Time { return now }))
+
+	if _, err := gen.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+
+	now = now.Add(-time.Millisecond)
+	if _, err := gen.Next(); !errors.Is(err, ErrClockMovedBackwards) {
+		t.Errorf("Next: got %v, want %v", err, ErrClockMovedBackwards)
+	}
+	if _, err := gen.NextN(10); !errors.Is(err, ErrClockMovedBackwards) {
+		t.Errorf("NextN: got %v, want %v", err, ErrClockMovedBackwards)
+	}
+}
+
+// BenchmarkIDGeneratorNext benchmarks reserving IDs one at a time from many goroutines.
+func BenchmarkIDGeneratorNext(b *testing.B) {
+	gen := NewIDGenerator()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			gen.Next()
+		}
+	})
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N), "ns/id")
+}
+
+// BenchmarkIDGeneratorNextN benchmarks reserving IDs in batches from many goroutines.
+// Each iteration reserves a full batch, so compare the ns/id metric with BenchmarkIDGeneratorNext.
+func BenchmarkIDGeneratorNextN(b *testing.B) {
+	for _, n := range []int{16, 128, 1024} {
+		b.Run(fmt.Sprintf("batch-%d", n), func(b *testing.B) {
+			gen := NewIDGenerator()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					gen.NextN(n)
+				}
+			})
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/id")
+		})
+	}
+}
+
+// BenchmarkGetNodeID benchmarks the interface lookup that used to run on every ID.
+func BenchmarkGetNodeID(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		getNodeID()
+	}
+}
+
+// fakeClock is a manually advanced clock for deterministic tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+func readStateMark(t *testing.T, path string) int64 {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading state file: %v", err)
+	}
+	mark, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		t.Fatalf("parsing state file: %v", err)
+	}
+	return mark
+}
+
+func TestIDGeneratorStateFileCrashRecovery(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	start := time.UnixMilli(1_700_000_000_000)
+
+	testCases := []struct {
+		name string
+		// restartAt is the clock reading when the generator comes back up.
+		restartAt time.Time
+		wantErr   error
+	}{
+		{"same millisecond", start, ErrStateMarkAhead},
+		{"inside reserved interval", start.Add(interval / 2), ErrStateMarkAhead},
+		{"clock rolled back", start.Add(-time.Hour), ErrStateMarkAhead},
+		{"after reserved interval", start.Add(interval), nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "id.state")
+			clock := &fakeClock{now: start}
+
+			before := NewIDGenerator(WithNodeID(1), WithClock(clock.Now), WithStateFile(path, interval))
+			issued, err := before.NextN(100)
+			if err != nil {
+				t.Fatalf("NextN failed: %v", err)
+			}
+			// Simulate a crash: the generator is abandoned without any shutdown
+			// and a new process starts with the same state file.
+			clock.Set(tc.restartAt)
+			after := NewIDGenerator(WithNodeID(1), WithClock(clock.Now), WithStateFile(path, interval))
+
+			id, err := after.Next()
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Next after restart: got %v, want %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if last := issued[len(issued)-1]; id <= last {
+				t.Errorf("ID after restart %d not above last issued %d", id, last)
+			}
+		})
+	}
+}
+
+func TestIDGeneratorStateFileRefusesUntilMark(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	path := filepath.Join(t.TempDir(), "id.state")
+	start := time.UnixMilli(1_700_000_000_000)
+	clock := &fakeClock{now: start}
+
+	before := NewIDGenerator(WithNodeID(1), WithClock(clock.Now), WithStateFile(path, interval))
+	last, err := before.Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+
+	after := NewIDGenerator(WithNodeID(1), WithClock(clock.Now), WithStateFile(path, interval))
+	for ts := start; ts.Before(start.Add(interval - time.Millisecond)); ts = ts.Add(time.Millisecond) {
+		clock.Set(ts)
+		if _, err := after.Next(); !errors.Is(err, ErrStateMarkAhead) {
+			t.Fatalf("Next at %v: got %v, want %v", ts.Sub(start), err, ErrStateMarkAhead)
+		}
+	}
+
+	// The millisecond right below the mark is treated as fully used, so the
+	// generator waits for the mark itself.
+	clock.Set(start.Add(interval - time.Millisecond))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		clock.Set(start.Add(interval))
+	}()
+	id, err := after.Next()
+	if err != nil {
+		t.Fatalf("Next at mark: %v", err)
+	}
+	if id <= last {
+		t.Errorf("ID after restart %d not above last issued %d", id, last)
+	}
+	if got, want := id>>tsShift, start.Add(interval).UnixMilli(); got != want {
+		t.Errorf("timestamp after restart: got %d, want %d", got, want)
+	}
+}
+
+func TestIDGeneratorStateFileCadence(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	path := filepath.Join(t.TempDir(), "id.state")
+	start := time.UnixMilli(1_700_000_000_000)
+	clock := &fakeClock{now: start}
+
+	gen := NewIDGenerator(WithNodeID(1), WithClock(clock.Now), WithStateFile(path, interval))
+	if _, err := gen.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	want := start.Add(interval).UnixMilli()
+	if got := readStateMark(t, path); got != want {
+		t.Fatalf("mark after first ID: got %d, want %d", got, want)
+	}
+
+	// IDs below the mark don't touch the state file.
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("removing state file: %v", err)
+	}
+	clock.Set(start.Add(interval - time.Millisecond))
+	if _, err := gen.NextN(10); err != nil {
+		t.Fatalf("NextN failed: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("state file rewritten below mark: %v", err)
+	}
+
+	// Reaching the mark records a new one.
+	clock.Set(start.Add(interval))
+	if _, err := gen.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	want = start.Add(2 * interval).UnixMilli()
+	if got := readStateMark(t, path); got != want {
+		t.Errorf("mark after crossing: got %d, want %d", got, want)
+	}
+}
+
+func TestIDGeneratorStateFileTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "id.state")
+	start := time.UnixMilli(1_700_000_000_000)
+	clock := &fakeClock{now: start}
+
+	before := NewIDGenerator(WithNodeID(1), WithClock(clock.Now), WithStateFile(path, time.Second))
+	if _, err := before.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+
+	// Simulate a crash halfway through the next write: the temporary file is
+	// garbage but the rename never happened.
+	leftover, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		t.Fatalf("creating temp file: %v", err)
+	}
+	leftover.WriteString("17000")
+	leftover.Close()
+
+	// The mark from before the crash is loaded, not the torn one.
+	after := NewIDGenerator(WithNodeID(1), WithClock(clock.Now), WithStateFile(path, time.Second))
+	if _, err := after.Next(); !errors.Is(err, ErrStateMarkAhead) {
+		t.Errorf("Next after torn write: got %v, want %v", err, ErrStateMarkAhead)
+	}
+
+	// Once the clock passes the mark, the next save replaces the state file
+	// alongside the leftover.
+	now := start.Add(2 * time.Second)
+	clock.Set(now)
+	if _, err := after.Next(); err != nil {
+		t.Fatalf("Next past the mark failed: %v", err)
+	}
+	if got, want := readStateMark(t, path), now.Add(time.Second).UnixMilli(); got != want {
+		t.Errorf("state mark after save: got %d, want %d", got, want)
+	}
+}
+
+func TestIDGeneratorStateFileCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "id.state")
+	if err := os.WriteFile(path, []byte("not-a-timestamp"), 0o644); err != nil {
+		t.Fatalf("writing state file: %v", err)
+	}
+
+	gen := NewIDGenerator(WithNodeID(1), WithStateFile(path, time.Second))
+	if _, err := gen.Next(); err == nil {
+		t.Error("Next with corrupt state file: got nil error")
+	}
+}
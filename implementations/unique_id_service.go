@@ -0,0 +1,282 @@
+package implementations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxBatchSize caps the number of IDs a single request may reserve.
+const maxBatchSize = 10_000
+
+// batchIDSource is implemented by ID sources that can reserve many IDs at once.
+type batchIDSource interface {
+	NextN(n int) ([]int64, error)
+}
+
+// IDServer exposes an IDSource over HTTP.
+//
+//	GET /id                  -> {"id": 1}
+//	GET /ids?count=N         -> {"ids": [1, 2, ...]}
+//	GET /decompose?id=ID     -> {"id": 1, "timestamp": "...", "timestamp_ms": 0, "node_id": 0, "step": 0}
+//	GET /healthz             -> ok
+//	GET /metrics             -> Prometheus text format
+//
+// Errors are reported as {"error": "..."} with a non-2xx status code.
+type IDServer struct {
+	source IDSource
+	mux    *http.ServeMux
+
+	requests  atomic.Int64
+	issued    atomic.Int64
+	failures  atomic.Int64
+	startedAt time.Time
+}
+
+// NewIDServer creates a new ID server issuing IDs from source.
+func NewIDServer(source IDSource) *IDServer {
+	s := &IDServer{
+		source:    source,
+		mux:       http.NewServeMux(),
+		startedAt: time.Now(),
+	}
+	s.mux.HandleFunc("/id", s.handleID)
+	s.mux.HandleFunc("/ids", s.handleIDs)
+	s.mux.HandleFunc("/decompose", s.handleDecompose)
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *IDServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+type idResponse struct {
+	ID int64 `json:"id"`
+}
+
+type idsResponse struct {
+	IDs []int64 `json:"ids"`
+}
+
+type decomposeResponse struct {
+	ID          int64     `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	TimestampMs int64     `json:"timestamp_ms"`
+	NodeID      int64     `json:"node_id"`
+	Step        int64     `json:"step"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *IDServer) handleID(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	id, err := s.source.Next()
+	if err != nil {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	s.issued.Add(1)
+	writeJSON(w, http.StatusOK, idResponse{ID: id})
+}
+
+func (s *IDServer) handleIDs(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	n, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || n <= 0 || n > maxBatchSize {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("count must be between 1 and %d", maxBatchSize))
+		return
+	}
+
+	ids, err := s.nextN(n)
+	if err != nil {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	s.issued.Add(int64(len(ids)))
+	writeJSON(w, http.StatusOK, idsResponse{IDs: ids})
+}
+
+func (s *IDServer) nextN(n int) ([]int64, error) {
+	if b, ok := s.source.(batchIDSource); ok {
+		return b.NextN(n)
+	}
+
+	ids := make([]int64, n)
+	for i := range ids {
+		id, err := s.source.Next()
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func (s *IDServer) handleDecompose(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id < 0 {
+		s.writeError(w, http.StatusBadRequest, errors.New("id must be a non-negative integer"))
+		return
+	}
+
+	parts := DecomposeID(id)
+	writeJSON(w, http.StatusOK, decomposeResponse{
+		ID:          id,
+		Timestamp:   parts.Timestamp.UTC(),
+		TimestampMs: parts.Timestamp.UnixMilli(),
+		NodeID:      parts.NodeID,
+		Step:        parts.Step,
+	})
+}
+
+func (s *IDServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "ok\n")
+}
+
+func (s *IDServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintf(w, "# HELP id_service_requests_total Requests for IDs or decompositions.\n")
+	fmt.Fprintf(w, "# TYPE id_service_requests_total counter\n")
+	fmt.Fprintf(w, "id_service_requests_total %d\n", s.requests.Load())
+	fmt.Fprintf(w, "# HELP id_service_ids_issued_total IDs handed out.\n")
+	fmt.Fprintf(w, "# TYPE id_service_ids_issued_total counter\n")
+	fmt.Fprintf(w, "id_service_ids_issued_total %d\n", s.issued.Load())
+	fmt.Fprintf(w, "# HELP id_service_errors_total Requests that failed.\n")
+	fmt.Fprintf(w, "# TYPE id_service_errors_total counter\n")
+	fmt.Fprintf(w, "id_service_errors_total %d\n", s.failures.Load())
+	fmt.Fprintf(w, "# HELP id_service_uptime_seconds Seconds since the server started.\n")
+	fmt.Fprintf(w, "# TYPE id_service_uptime_seconds gauge\n")
+	fmt.Fprintf(w, "id_service_uptime_seconds %g\n", time.Since(s.startedAt).Seconds())
+}
+
+func (s *IDServer) writeError(w http.ResponseWriter, status int, err error) {
+	s.failures.Add(1)
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// IDServiceError is returned by IDClient when the server responds with an error.
+type IDServiceError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *IDServiceError) Error() string {
+	return fmt.Sprintf("id service: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IDClient talks to an IDServer.
+type IDClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewIDClient creates a new client for the ID server at baseURL.
+// If httpClient is nil, http.DefaultClient is used.
+func NewIDClient(baseURL string, httpClient *http.Client) *IDClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &IDClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// Next returns a new unique ID.
+func (c *IDClient) Next() (int64, error) {
+	var resp idResponse
+	if err := c.get("/id", nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+// NextN returns n new unique IDs.
+func (c *IDClient) NextN(n int) ([]int64, error) {
+	if n <= 0 {
+		return nil, ErrInvalidBatchSize
+	}
+
+	var resp idsResponse
+	if err := c.get("/ids", url.Values{"count": {strconv.Itoa(n)}}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.IDs) != n {
+		return nil, fmt.Errorf("id service: got %d IDs, want %d", len(resp.IDs), n)
+	}
+	return resp.IDs, nil
+}
+
+// Decompose asks the server to unpack id.
+func (c *IDClient) Decompose(id int64) (IDParts, error) {
+	var resp decomposeResponse
+	if err := c.get("/decompose", url.Values{"id": {strconv.FormatInt(id, 10)}}, &resp); err != nil {
+		return IDParts{}, err
+	}
+	return IDParts{
+		Timestamp: time.UnixMilli(resp.TimestampMs),
+		NodeID:    resp.NodeID,
+		Step:      resp.Step,
+	}, nil
+}
+
+// Healthy reports whether the server is up.
+func (c *IDClient) Healthy() error {
+	resp, err := c.httpClient.Get(c.baseURL + "/healthz")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return &IDServiceError{StatusCode: resp.StatusCode, Message: "unhealthy"}
+	}
+	return nil
+}
+
+func (c *IDClient) get(path string, query url.Values, v any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	resp, err := c.httpClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+			e.Error = resp.Status
+		}
+		return &IDServiceError{StatusCode: resp.StatusCode, Message: e.Error}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,192 @@
+package implementations
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIDServiceUniqueConcurrent(t *testing.T) {
+	const (
+		numClients     = 32
+		callsPerClient = 200
+	)
+
+	srv := httptest.NewServer(NewIDServer(NewIDGenerator(WithNodeID(3))))
+	defer srv.Close()
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]struct{})
+		wg   sync.WaitGroup
+	)
+	for c := 0; c < numClients; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			client := NewIDClient(srv.URL, srv.Client())
+			ids := make([]int64, 0, callsPerClient)
+			for i := 0; i < callsPerClient; i++ {
+				if c%2 == 0 {
+					id, err := client.Next()
+					if err != nil {
+						t.Errorf("Next failed: %v", err)
+						return
+					}
+					ids = append(ids, id)
+					continue
+				}
+				batch, err := client.NextN(5)
+				if err != nil {
+					t.Errorf("NextN failed: %v", err)
+					return
+				}
+				ids = append(ids, batch...)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, id := range ids {
+				if _, ok := seen[id]; ok {
+					t.Errorf("duplicate ID %d", id)
+				}
+				seen[id] = struct{}{}
+			}
+		}(c)
+	}
+	wg.Wait()
+}
+
+func TestIDServiceSegmentSource(t *testing.T) {
+	// Sources without NextN are served one ID at a time.
+	srv := httptest.NewServer(NewIDServer(NewSegmentAllocator(NewMemorySegmentStore(), "orders")))
+	defer srv.Close()
+
+	ids, err := NewIDClient(srv.URL, srv.Client()).NextN(3)
+	if err != nil {
+		t.Fatalf("NextN failed: %v", err)
+	}
+	for i, id := range ids {
+		if id != int64(i) {
+			t.Errorf("ids[%d]: got %d, want %d", i, id, i)
+		}
+	}
+}
+
+func TestIDServiceDecompose(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_123)
+	gen := NewIDGenerator(WithNodeID(42), WithClock(func() time.Time { return now }))
+	srv := httptest.NewServer(NewIDServer(gen))
+	defer srv.Close()
+
+	client := NewIDClient(srv.URL, srv.Client())
+	if _, err := client.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	id, err := client.Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+
+	got, err := client.Decompose(id)
+	if err != nil {
+		t.Fatalf("Decompose failed: %v", err)
+	}
+	if !got.Timestamp.Equal(now) || got.NodeID != 42 || got.Step != 1 {
+		t.Errorf("Decompose: got %+v, want timestamp %v, node 42, step 1", got, now)
+	}
+}
+
+func TestIDServiceErrors(t *testing.T) {
+	srv := httptest.NewServer(NewIDServer(NewIDGenerator(WithNodeID(1))))
+	defer srv.Close()
+
+	testCases := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/ids?count=0", http.StatusBadRequest},
+		{"/ids?count=abc", http.StatusBadRequest},
+		{"/ids?count=10001", http.StatusBadRequest},
+		{"/decompose?id=-1", http.StatusBadRequest},
+		{"/decompose", http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		resp, err := srv.Client().Get(srv.URL + tc.path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.wantStatus {
+			t.Errorf("GET %s: got status %d, want %d", tc.path, resp.StatusCode, tc.wantStatus)
+		}
+	}
+
+	resp, err := srv.Client().Post(srv.URL+"/id", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST /id: got status %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+
+	// Generator failures surface as service errors.
+	now := time.UnixMilli(1_700_000_000_000)
+	gen := NewIDGenerator(WithNodeID(1), WithClock(func() time.Time { return now }))
+	broken := httptest.NewServer(NewIDServer(gen))
+	defer broken.Close()
+	brokenClient := NewIDClient(broken.URL, broken.Client())
+	if _, err := brokenClient.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	now = now.Add(-time.Second)
+	var svcErr *IDServiceError
+	if _, err := brokenClient.Next(); !errors.As(err, &svcErr) || svcErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Next with clock rollback: got %v, want status %d", err, http.StatusServiceUnavailable)
+	}
+}
+
+func TestIDServiceHealthAndMetrics(t *testing.T) {
+	srv := httptest.NewServer(NewIDServer(NewIDGenerator(WithNodeID(1))))
+	defer srv.Close()
+	client := NewIDClient(srv.URL, srv.Client())
+
+	if err := client.Healthy(); err != nil {
+		t.Fatalf("Healthy failed: %v", err)
+	}
+	if _, err := client.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if _, err := client.NextN(4); err != nil {
+		t.Fatalf("NextN failed: %v", err)
+	}
+	if _, err := client.NextN(maxBatchSize + 1); err == nil {
+		t.Fatal("NextN over the limit: got nil error")
+	}
+
+	resp, err := srv.Client().Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading metrics: %v", err)
+	}
+
+	for _, want := range []string{
+		"id_service_requests_total 3\n",
+		"id_service_ids_issued_total 5\n",
+		"id_service_errors_total 1\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package cache
+
+// ARCEvictionPolicy tracks arbitrary keys.
+type ARCEvictionPolicy = TypedARCEvictionPolicy[any]
+
+// TypedARCEvictionPolicy implements Adaptive Replacement Cache. Resident keys
+// are split between t1, keys seen once recently, and t2, keys seen at least
+// twice. The ghost lists b1 and b2 remember keys recently evicted from each,
+// and a hit on a ghost shifts the target size of t1 towards whichever list
+// would have kept the key. A one-off scan only churns t1, leaving the frequently
+// used keys in t2 alone.
+type TypedARCEvictionPolicy[K comparable] struct {
+	capacity int
+	p        int // Target size of t1.
+
+	t1, t2, b1, b2 *TypedDoublyLinkedList[K]
+	lut            map[K]*listEntry[K]
+}
+
+// listEntry locates a key in one of several lists.
+type listEntry[K comparable] struct {
+	node *TypedDoubleLinkNode[K]
+	list *TypedDoublyLinkedList[K]
+}
+
+func NewARCEvictionPolicy(capacity int) *ARCEvictionPolicy {
+	return NewTypedARCEvictionPolicy[any](capacity)
+}
+
+// NewTypedARCEvictionPolicy creates an ARC policy for a cache holding capacity keys.
+func NewTypedARCEvictionPolicy[K comparable](capacity int) *TypedARCEvictionPolicy[K] {
+	return &TypedARCEvictionPolicy[K]{
+		capacity: capacity,
+		t1:       new(TypedDoublyLinkedList[K]),
+		t2:       new(TypedDoublyLinkedList[K]),
+		b1:       new(TypedDoublyLinkedList[K]),
+		b2:       new(TypedDoublyLinkedList[K]),
+		lut:      make(map[K]*listEntry[K]),
+	}
+}
+
+func (a *TypedARCEvictionPolicy[K]) ItemAccessed(item K) {
+	entry, ok := a.lut[item]
+	if !ok {
+		a.lut[item] = &listEntry[K]{node: a.t1.AddItemToHead(item), list: a.t1}
+		a.trimGhosts()
+		return
+	}
+
+	switch entry.list {
+	case a.b1:
+		a.p = min(a.capacity, a.p+max(a.b2.size/a.b1.size, 1))
+	case a.b2:
+		a.p = max(0, a.p-max(a.b1.size/a.b2.size, 1))
+	}
+	a.move(entry, a.t2)
+}
+
+// Victim returns the key Evict would remove without removing it.
+func (a *TypedARCEvictionPolicy[K]) Victim() (K, bool) {
+	from, _ := a.replaceFrom()
+	if from.tail == nil {
+		var zero K
+		return zero, false
+	}
+	return from.tail.Val, true
+}
+
+// Evict moves the least recently used key of t1 or t2 to its ghost list and
+// returns it, or returns the zero value if no keys are resident.
+func (a *TypedARCEvictionPolicy[K]) Evict() K {
+	from, ghost := a.replaceFrom()
+	node := from.tail
+	if node == nil {
+		var zero K
+		return zero
+	}
+	a.move(a.lut[node.Val], ghost)
+	return node.Val
+}
+
+// replaceFrom picks the resident list to evict from and the ghost list the
+// evicted key moves to.
+func (a *TypedARCEvictionPolicy[K]) replaceFrom() (from, ghost *TypedDoublyLinkedList[K]) {
+	if a.t1.size > 0 && (a.t1.size > a.p || a.t2.size == 0) {
+		return a.t1, a.b1
+	}
+	return a.t2, a.b2
+}
+
+// Remove forgets item entirely, without remembering it as a ghost.
+func (a *TypedARCEvictionPolicy[K]) Remove(item K) {
+	if entry, ok := a.lut[item]; ok {
+		entry.list.RemoveNode(entry.node)
+		delete(a.lut, item)
+	}
+}
+
+// trimGhosts bounds t1+b1 to the capacity and all four lists to twice the capacity.
+func (a *TypedARCEvictionPolicy[K]) trimGhosts() {
+	for a.t1.size+a.b1.size > a.capacity && a.b1.size > 0 {
+		delete(a.lut, a.b1.RemoveTail().Val)
+	}
+	for a.t1.size+a.t2.size+a.b1.size+a.b2.size > 2*a.capacity {
+		ghost := a.b2
+		if ghost.size == 0 {
+			ghost = a.b1
+		}
+		node := ghost.RemoveTail()
+		if node == nil {
+			return
+		}
+		delete(a.lut, node.Val)
+	}
+}
+
+func (a *TypedARCEvictionPolicy[K]) move(entry *listEntry[K], to *TypedDoublyLinkedList[K]) {
+	entry.list.RemoveNode(entry.node)
+	to.AddToHead(entry.node)
+	entry.list = to
+}
+
+// exportState lists t1 and then t2, each least recently used first, along with
+// the ghosts in b1 and b2 and the target size of t1.
+func (a *TypedARCEvictionPolicy[K]) exportState() policyState[K] {
+	s := policyState[K]{Target: a.p}
+	for i, list := range []*TypedDoublyLinkedList[K]{a.t1, a.t2} {
+		for node := list.tail; node != nil; node = node.Prev {
+			s.Keys = append(s.Keys, policyKey[K]{Key: node.Val, List: i})
+		}
+	}
+	for i, list := range []*TypedDoublyLinkedList[K]{a.b1, a.b2} {
+		for node := list.tail; node != nil; node = node.Prev {
+			s.Ghosts = append(s.Ghosts, policyKey[K]{Key: node.Val, List: i})
+		}
+	}
+	return s
+}
+
+func (a *TypedARCEvictionPolicy[K]) importState(s policyState[K]) {
+	resident := []*TypedDoublyLinkedList[K]{a.t1, a.t2}
+	for _, k := range s.Keys {
+		if entry, ok := a.lut[k.Key]; ok {
+			a.move(entry, stateList(resident, k.List))
+		}
+	}
+	ghosts := []*TypedDoublyLinkedList[K]{a.b1, a.b2}
+	for _, k := range s.Ghosts {
+		if _, ok := a.lut[k.Key]; !ok {
+			list := stateList(ghosts, k.List)
+			a.lut[k.Key] = &listEntry[K]{node: list.AddItemToHead(k.Key), list: list}
+		}
+	}
+	a.p = min(max(s.Target, 0), a.capacity)
+	a.trimGhosts()
+}
@@ -0,0 +1,384 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BackedCache works with arbitrary keys and values.
+type BackedCache = TypedBackedCache[any, any]
+
+// WriteMode decides how a TypedBackedCache propagates writes to its backend.
+// Reads are always read-through: a miss loads the key from the backend.
+type WriteMode int
+
+const (
+	// WriteAround writes to the backend and drops the cached copy, so the
+	// next read loads the new value.
+	WriteAround WriteMode = iota
+	// WriteThrough writes to the backend and then to the cache. A write
+	// that fails in the backend leaves the cache untouched.
+	WriteThrough
+	// WriteBehind writes to the cache and queues the write for the backend.
+	// Queued writes are flushed in batches in the background, in the order
+	// keys were first written, with only the latest write to each key
+	// applied. A write that keeps failing doesn't hold up the others. Reads
+	// see queued writes even if the cache has evicted them.
+	WriteBehind
+)
+
+func (m WriteMode) String() string {
+	switch m {
+	case WriteAround:
+		return "write-around"
+	case WriteThrough:
+		return "write-through"
+	case WriteBehind:
+		return "write-behind"
+	default:
+		return fmt.Sprintf("WriteMode(%d)", int(m))
+	}
+}
+
+// TypedBackedCache keeps a TypedCache in front of a TypedBackend.
+//
+// Writes to the same key from concurrent goroutines may reach the backend in
+// either order, and a read-through load racing with a write may cache the
+// value it loaded just before the write landed.
+type TypedBackedCache[K comparable, V any] struct {
+	loading *TypedLoadingCache[K, V]
+	cache   *TypedCache[K, V]
+	backend TypedBackend[K, V]
+	mode    WriteMode
+	config  BackedCacheConfig
+
+	// Write-behind state. mu guards pending, order and onFlushError;
+	// flushMu serializes flushes so a key is never written by two of them at
+	// once.
+	mu           sync.Mutex
+	flushMu      sync.Mutex
+	pending      map[K]*pendingWrite[V]
+	order        []K
+	seq          uint64
+	onFlushError func(key K, err error)
+	kick         chan struct{}
+	stop         chan struct{}
+	done         chan struct{}
+	once         sync.Once
+}
+
+// pendingWrite is the latest write to a key that hasn't reached the backend.
+type pendingWrite[V any] struct {
+	value   V
+	deleted bool
+	seq     uint64
+	queued  bool // Whether the key is in order.
+}
+
+// BackedCacheConfig is needed to create a new backed cache.
+type BackedCacheConfig struct {
+	// BatchSize is the most writes flushed to the backend at once. A flush
+	// starts early once this many keys are queued.
+	// Default is 100.
+	BatchSize int
+	// FlushInterval is how often queued writes are flushed.
+	// Default is 100ms.
+	FlushInterval time.Duration
+	// MaxRetries is how many times a failed backend write is retried within
+	// a flush before it is put back in the queue for the next one.
+	// Default is 3.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry. It doubles with
+	// every retry.
+	// Default is 10ms.
+	RetryBackoff time.Duration
+}
+
+// BackedCacheOption is used to configure a new backed cache.
+type BackedCacheOption func(*BackedCacheConfig)
+
+// WithBatchSize sets the most writes flushed to the backend at once.
+func WithBatchSize(n int) BackedCacheOption {
+	return func(c *BackedCacheConfig) {
+		c.BatchSize = n
+	}
+}
+
+// WithFlushInterval sets how often queued writes are flushed.
+func WithFlushInterval(d time.Duration) BackedCacheOption {
+	return func(c *BackedCacheConfig) {
+		c.FlushInterval = d
+	}
+}
+
+// WithRetry sets how many times a failed backend write is retried and the
+// wait before the first retry.
+func WithRetry(maxRetries int, backoff time.Duration) BackedCacheOption {
+	return func(c *BackedCacheConfig) {
+		c.MaxRetries = maxRetries
+		c.RetryBackoff = backoff
+	}
+}
+
+func NewBackedCache(cache *Cache, backend Backend, mode WriteMode, opts ...BackedCacheOption) *BackedCache {
+	return NewTypedBackedCache(cache, backend, mode, opts...)
+}
+
+// NewTypedBackedCache creates a cache that stores values in cache and keeps
+// them in sync with backend according to mode. Call Close to flush queued
+// writes and stop the background flusher.
+func NewTypedBackedCache[K comparable, V any](cache *TypedCache[K, V], backend TypedBackend[K, V], mode WriteMode, opts ...BackedCacheOption) *TypedBackedCache[K, V] {
+	const (
+		defaultBatchSize     = 100
+		defaultFlushInterval = 100 * time.Millisecond
+		defaultMaxRetries    = 3
+		defaultRetryBackoff  = 10 * time.Millisecond
+	)
+	c := BackedCacheConfig{
+		BatchSize:     defaultBatchSize,
+		FlushInterval: defaultFlushInterval,
+		MaxRetries:    defaultMaxRetries,
+		RetryBackoff:  defaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	c.BatchSize = max(c.BatchSize, 1)
+
+	bc := &TypedBackedCache[K, V]{
+		cache:   cache,
+		backend: backend,
+		mode:    mode,
+		config:  c,
+		pending: make(map[K]*pendingWrite[V]),
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	bc.loading = NewTypedLoadingCache(cache, bc.load)
+
+	if mode == WriteBehind {
+		go bc.flusher()
+	} else {
+		close(bc.done)
+	}
+	return bc
+}
+
+// Get returns the value for key, loading it from the backend on a miss.
+func (c *TypedBackedCache[K, V]) Get(key K) (V, error) {
+	return c.loading.Get(key)
+}
+
+// load reads key for the loading cache, preferring a queued write to the
+// backend's possibly older copy.
+func (c *TypedBackedCache[K, V]) load(key K) (V, error) {
+	c.mu.Lock()
+	w, ok := c.pending[key]
+	c.mu.Unlock()
+	if ok {
+		if w.deleted {
+			var zero V
+			return zero, ErrKeyNotFound
+		}
+		return w.value, nil
+	}
+	return c.backend.Load(key)
+}
+
+func (c *TypedBackedCache[K, V]) Put(key K, value V) error {
+	switch c.mode {
+	case WriteAround:
+		if err := c.backend.Store(key, value); err != nil {
+			return fmt.Errorf("storing key %v: %w", key, err)
+		}
+		c.cache.Delete(key)
+	case WriteThrough:
+		if err := c.backend.Store(key, value); err != nil {
+			return fmt.Errorf("storing key %v: %w", key, err)
+		}
+		return c.cache.Put(key, value)
+	case WriteBehind:
+		if err := c.cache.Put(key, value); err != nil {
+			return err
+		}
+		c.enqueue(key, value, false)
+	}
+	return nil
+}
+
+// Delete removes key from the cache and the backend.
+func (c *TypedBackedCache[K, V]) Delete(key K) error {
+	if c.mode == WriteBehind {
+		var zero V
+		c.enqueue(key, zero, true)
+		c.cache.Delete(key)
+		return nil
+	}
+
+	if err := c.backend.Delete(key); err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return fmt.Errorf("deleting key %v: %w", key, err)
+	}
+	c.cache.Delete(key)
+	return nil
+}
+
+func (c *TypedBackedCache[K, V]) enqueue(key K, value V, deleted bool) {
+	c.mu.Lock()
+	c.seq++
+	w, ok := c.pending[key]
+	if !ok || !w.queued {
+		c.order = append(c.order, key)
+	}
+	c.pending[key] = &pendingWrite[V]{value: value, deleted: deleted, seq: c.seq, queued: true}
+	full := len(c.order) >= c.config.BatchSize
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// OnFlushError sets fn to be called with the last error when a queued write
+// still fails after MaxRetries retries, replacing any earlier hook. fn runs on
+// the goroutine doing the flush.
+func (c *TypedBackedCache[K, V]) OnFlushError(fn func(key K, err error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onFlushError = fn
+}
+
+// Pending returns the number of keys with writes not yet in the backend.
+func (c *TypedBackedCache[K, V]) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+func (c *TypedBackedCache[K, V]) flusher() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.Flush()
+		case <-c.kick:
+			c.Flush()
+		}
+	}
+}
+
+// Flush writes every queued write to the backend, in batches of BatchSize.
+// Writes that still fail after retrying stay queued for the next flush; the
+// returned error is the last such failure.
+func (c *TypedBackedCache[K, V]) Flush() error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	var lastErr error
+	var failed []K
+	for {
+		keys, writes := c.nextBatch()
+		if len(keys) == 0 {
+			break
+		}
+		for i, key := range keys {
+			if err := c.write(key, writes[i]); err != nil {
+				lastErr = err
+				failed = append(failed, key)
+				continue
+			}
+			c.mu.Lock()
+			if w := c.pending[key]; w != nil && w.seq == writes[i].seq {
+				delete(c.pending, key)
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	// Requeue failures only now so one flush doesn't retry them forever.
+	c.mu.Lock()
+	for _, key := range failed {
+		if w := c.pending[key]; w != nil && !w.queued {
+			w.queued = true
+			c.order = append(c.order, key)
+		}
+	}
+	c.mu.Unlock()
+
+	return lastErr
+}
+
+// nextBatch takes up to BatchSize keys off the queue. The writes stay in
+// pending until they succeed so reads keep seeing them.
+func (c *TypedBackedCache[K, V]) nextBatch() ([]K, []pendingWrite[V]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := min(len(c.order), c.config.BatchSize)
+	keys := append([]K(nil), c.order[:n]...)
+	c.order = c.order[n:]
+
+	writes := make([]pendingWrite[V], n)
+	for i, key := range keys {
+		w := c.pending[key]
+		w.queued = false
+		writes[i] = *w
+	}
+	return keys, writes
+}
+
+// write applies w to the backend, retrying with exponential backoff.
+func (c *TypedBackedCache[K, V]) write(key K, w pendingWrite[V]) error {
+	backoff := c.config.RetryBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if w.deleted {
+			err = c.backend.Delete(key)
+			if errors.Is(err, ErrKeyNotFound) {
+				err = nil
+			}
+		} else {
+			err = c.backend.Store(key, w.value)
+		}
+		if err == nil || attempt == c.config.MaxRetries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	if err != nil {
+		err = fmt.Errorf("flushing key %v: %w", key, err)
+		c.mu.Lock()
+		fn := c.onFlushError
+		c.mu.Unlock()
+		if fn != nil {
+			fn(key, err)
+		}
+	}
+	return err
+}
+
+// Close stops the background flusher, flushes queued writes and closes the
+// cache. It returns the flush error, if any.
+func (c *TypedBackedCache[K, V]) Close() error {
+	c.once.Do(func() { close(c.stop) })
+	<-c.done
+
+	var err error
+	if c.mode == WriteBehind {
+		err = c.Flush()
+	}
+	c.cache.Close()
+	return err
+}
@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errBackendDown = errors.New("backend down")
+
+func newTestBackedCache(capacity int, mode WriteMode, opts ...BackedCacheOption) (*TypedBackedCache[string, int], *TypedMemoryBackend[string, int]) {
+	backend := NewTypedMemoryBackend[string, int]()
+	c := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](capacity), NewTypedLRUEvictionPolicy[string]())
+	// Flush only when a test asks for it, unless it sets its own interval.
+	opts = append([]BackedCacheOption{WithFlushInterval(time.Hour), WithRetry(0, 0)}, opts...)
+	return NewTypedBackedCache[string, int](c, backend, mode, opts...), backend
+}
+
+func TestBackedCacheReadThrough(t *testing.T) {
+	for _, mode := range []WriteMode{WriteAround, WriteThrough, WriteBehind} {
+		t.Run(mode.String(), func(t *testing.T) {
+			c, backend := newTestBackedCache(10, mode)
+			defer c.Close()
+			backend.Store("a", 1)
+
+			for i := 0; i < 3; i++ {
+				if got, err := c.Get("a"); err != nil || got != 1 {
+					t.Fatalf("Get(a): got %d, %v, want 1, nil", got, err)
+				}
+			}
+			if n := backend.Loads(); n != 1 {
+				t.Errorf("backend loaded %d times, want 1", n)
+			}
+			if _, err := c.Get("missing"); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("Get(missing): got %v, want ErrKeyNotFound", err)
+			}
+		})
+	}
+}
+
+func TestBackedCacheSyncWrites(t *testing.T) {
+	testCases := []struct {
+		mode       WriteMode
+		wantCached bool
+	}{
+		{WriteAround, false},
+		{WriteThrough, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mode.String(), func(t *testing.T) {
+			c, backend := newTestBackedCache(10, tc.mode)
+			defer c.Close()
+
+			backend.Store("a", 1)
+			c.Get("a") // Cache a so Put must replace or drop it.
+
+			if err := c.Put("a", 2); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if got, _ := backend.Value("a"); got != 2 {
+				t.Errorf("backend value: got %d, want 2", got)
+			}
+			if _, err := c.cache.Get("a"); (err == nil) != tc.wantCached {
+				t.Errorf("cached after Put: got %v, want %v", err == nil, tc.wantCached)
+			}
+			if got, err := c.Get("a"); err != nil || got != 2 {
+				t.Errorf("Get(a): got %d, %v, want 2, nil", got, err)
+			}
+
+			if err := c.Delete("a"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if _, ok := backend.Value("a"); ok {
+				t.Error("key still in backend after Delete")
+			}
+			if _, err := c.Get("a"); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("Get after Delete: got %v, want ErrKeyNotFound", err)
+			}
+		})
+	}
+}
+
+func TestBackedCacheWriteThroughFailure(t *testing.T) {
+	c, backend := newTestBackedCache(10, WriteThrough)
+	defer c.Close()
+	c.Put("a", 1)
+
+	backend.SetError(errBackendDown)
+	if err := c.Put("a", 2); !errors.Is(err, errBackendDown) {
+		t.Fatalf("Put: got %v, want %v", err, errBackendDown)
+	}
+	// The cache must not get ahead of the backend.
+	if got, err := c.Get("a"); err != nil || got != 1 {
+		t.Errorf("Get(a): got %d, %v, want 1, nil", got, err)
+	}
+}
+
+func TestBackedCacheWriteBehindCoalescesInOrder(t *testing.T) {
+	c, backend := newTestBackedCache(10, WriteBehind)
+	defer c.Close()
+
+	c.Put("a", 1)
+	c.Put("b", 1)
+	c.Put("a", 2)
+	c.Put("c", 1)
+	c.Delete("b")
+
+	if got := backend.Writes(); len(got) != 0 {
+		t.Fatalf("backend written before flush: %v", got)
+	}
+	if got := c.Pending(); got != 3 {
+		t.Errorf("Pending: got %d, want 3", got)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	want := []BackendWrite[string, int]{
+		{Key: "a", Value: 2},
+		{Key: "b", Deleted: true},
+		{Key: "c", Value: 1},
+	}
+	if got := backend.Writes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("backend writes:\ngot  %v\nwant %v", got, want)
+	}
+	if got := c.Pending(); got != 0 {
+		t.Errorf("Pending after flush: got %d, want 0", got)
+	}
+}
+
+func TestBackedCacheWriteBehindReadsQueuedWrites(t *testing.T) {
+	c, backend := newTestBackedCache(1, WriteBehind)
+	defer c.Close()
+	backend.Store("a", 1)
+	backend.Store("b", 1)
+
+	c.Put("a", 2)
+	c.Delete("b")
+	c.Put("c", 3) // Evicts a from the cache before it is flushed.
+
+	if got, err := c.Get("a"); err != nil || got != 2 {
+		t.Errorf("Get(a): got %d, %v, want the queued 2, nil", got, err)
+	}
+	if _, err := c.Get("b"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get(b): got %v, want ErrKeyNotFound for queued delete", err)
+	}
+}
+
+func TestBackedCacheWriteBehindRetry(t *testing.T) {
+	var failures atomic.Int32
+	c, backend := newTestBackedCache(10, WriteBehind)
+	defer c.Close()
+	c.OnFlushError(func(key string, err error) {
+		if key != "a" {
+			t.Errorf("OnFlushError called for %q, want a", key)
+		}
+		failures.Add(1)
+	})
+
+	c.Put("a", 1)
+	backend.SetError(errBackendDown)
+	if err := c.Flush(); !errors.Is(err, errBackendDown) {
+		t.Fatalf("Flush: got %v, want %v", err, errBackendDown)
+	}
+	if n := failures.Load(); n != 1 {
+		t.Errorf("OnFlushError called %d times, want 1", n)
+	}
+	if got := c.Pending(); got != 1 {
+		t.Fatalf("Pending after failed flush: got %d, want 1", got)
+	}
+
+	backend.SetError(nil)
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if got, _ := backend.Value("a"); got != 1 {
+		t.Errorf("backend value: got %d, want 1", got)
+	}
+}
+
+// flakyBackend fails the first n writes.
+type flakyBackend struct {
+	*TypedMemoryBackend[string, int]
+	failures atomic.Int32
+}
+
+func (b *flakyBackend) Store(key string, value int) error {
+	if b.failures.Add(-1) >= 0 {
+		return errBackendDown
+	}
+	return b.TypedMemoryBackend.Store(key, value)
+}
+
+func TestBackedCacheWriteBehindRetriesWithinFlush(t *testing.T) {
+	backend := &flakyBackend{TypedMemoryBackend: NewTypedMemoryBackend[string, int]()}
+	backend.failures.Store(2)
+	c := NewTypedBackedCache[string, int](
+		NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), NewTypedLRUEvictionPolicy[string]()),
+		backend, WriteBehind, WithFlushInterval(time.Hour), WithRetry(2, time.Millisecond),
+	)
+	defer c.Close()
+
+	c.Put("a", 1)
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush failed after retries: %v", err)
+	}
+	if got, _ := backend.Value("a"); got != 1 {
+		t.Errorf("backend value: got %d, want 1", got)
+	}
+}
+
+func TestBackedCacheWriteBehindBackgroundFlush(t *testing.T) {
+	c, backend := newTestBackedCache(100, WriteBehind, WithBatchSize(5))
+	defer c.Close()
+
+	// A full batch starts a flush without waiting for the interval.
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		c.Put(key, i)
+	}
+	waitFor(t, func() bool { return len(backend.Writes()) == 5 })
+}
+
+func TestBackedCacheCloseFlushes(t *testing.T) {
+	c, backend := newTestBackedCache(10, WriteBehind)
+	c.Put("a", 1)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got, ok := backend.Value("a"); !ok || got != 1 {
+		t.Errorf("backend value after Close: got %d, %v, want 1, true", got, ok)
+	}
+}
@@ -0,0 +1,111 @@
+package cache
+
+import "sync"
+
+// Backend and MemoryBackend work with arbitrary keys and values.
+type (
+	Backend       = TypedBackend[any, any]
+	MemoryBackend = TypedMemoryBackend[any, any]
+)
+
+// TypedBackend is the slower system of record a cache sits in front of.
+type TypedBackend[K comparable, V any] interface {
+	// Load returns the value for key, or ErrKeyNotFound if there is none.
+	Load(key K) (V, error)
+	Store(key K, value V) error
+	Delete(key K) error
+}
+
+// BackendWrite is a write applied to a TypedMemoryBackend.
+type BackendWrite[K comparable, V any] struct {
+	Key     K
+	Value   V
+	Deleted bool
+}
+
+// TypedMemoryBackend is an in-memory Backend that records every write, for
+// testing cache strategies. It is safe for concurrent use.
+type TypedMemoryBackend[K comparable, V any] struct {
+	mu     sync.Mutex
+	data   map[K]V
+	writes []BackendWrite[K, V]
+	loads  int
+	err    error
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return NewTypedMemoryBackend[any, any]()
+}
+
+func NewTypedMemoryBackend[K comparable, V any]() *TypedMemoryBackend[K, V] {
+	return &TypedMemoryBackend[K, V]{data: make(map[K]V)}
+}
+
+func (b *TypedMemoryBackend[K, V]) Load(key K) (V, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.loads++
+	if b.err != nil {
+		var zero V
+		return zero, b.err
+	}
+	val, ok := b.data[key]
+	if !ok {
+		return val, ErrKeyNotFound
+	}
+	return val, nil
+}
+
+func (b *TypedMemoryBackend[K, V]) Store(key K, value V) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+	b.data[key] = value
+	b.writes = append(b.writes, BackendWrite[K, V]{Key: key, Value: value})
+	return nil
+}
+
+func (b *TypedMemoryBackend[K, V]) Delete(key K) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+	delete(b.data, key)
+	b.writes = append(b.writes, BackendWrite[K, V]{Key: key, Deleted: true})
+	return nil
+}
+
+// SetError makes every call fail with err until it is called again with nil.
+func (b *TypedMemoryBackend[K, V]) SetError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+// Writes returns the successful writes in the order they were applied.
+func (b *TypedMemoryBackend[K, V]) Writes() []BackendWrite[K, V] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]BackendWrite[K, V](nil), b.writes...)
+}
+
+// Loads returns the number of calls to Load.
+func (b *TypedMemoryBackend[K, V]) Loads() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.loads
+}
+
+// Value returns the stored value for key, bypassing the recorded stats.
+func (b *TypedMemoryBackend[K, V]) Value(key K) (V, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	val, ok := b.data[key]
+	return val, ok
+}
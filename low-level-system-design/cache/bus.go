@@ -0,0 +1,64 @@
+package cache
+
+import "sync"
+
+// Invalidation tells other instances that key changed, so they must drop any
+// copy older than Version.
+type Invalidation[K comparable] struct {
+	Key     K
+	Version uint64
+	// Source identifies the publishing instance, so it can ignore its own
+	// messages.
+	Source string
+}
+
+// InvalidationBus carries invalidations between instances. A bus may deliver
+// messages late, more than once or out of order, but must deliver each one
+// eventually to every subscriber.
+type InvalidationBus[K comparable] interface {
+	Publish(msg Invalidation[K]) error
+	// Subscribe calls handler with every message published from now on,
+	// until cancel is called.
+	Subscribe(handler func(Invalidation[K])) (cancel func())
+}
+
+// MemoryBroker is an InvalidationBus within one process, for tests and for
+// several caches in one binary. Publish calls every subscriber in turn before
+// returning. It is safe for concurrent use.
+type MemoryBroker[K comparable] struct {
+	mu          sync.RWMutex
+	subscribers map[int]func(Invalidation[K])
+	nextID      int
+}
+
+func NewMemoryBroker[K comparable]() *MemoryBroker[K] {
+	return &MemoryBroker[K]{subscribers: make(map[int]func(Invalidation[K]))}
+}
+
+func (b *MemoryBroker[K]) Publish(msg Invalidation[K]) error {
+	b.mu.RLock()
+	handlers := make([]func(Invalidation[K]), 0, len(b.subscribers))
+	for _, h := range b.subscribers {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(msg)
+	}
+	return nil
+}
+
+func (b *MemoryBroker[K]) Subscribe(handler func(Invalidation[K])) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = handler
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
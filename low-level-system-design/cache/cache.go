@@ -3,192 +3,319 @@ package cache
 import (
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 )
 
-type DoubleLinkNode struct {
-	Key  int
-	Val  any
-	Next *DoubleLinkNode
-	Prev *DoubleLinkNode
+// Cache and EvictionPolicy work with arbitrary keys and values. Use TypedCache
+// to avoid type assertions and boxing at call sites.
+type (
+	Cache           = TypedCache[any, any]
+	EvictionPolicy  = TypedEvictionPolicy[any]
+	AdmissionPolicy = TypedAdmissionPolicy[any]
+)
+
+type TypedEvictionPolicy[K comparable] interface {
+	ItemAccessed(item K)
+	// Evict stops tracking the next key to be evicted and returns it.
+	Evict() K
+	// Remove stops tracking item, which left the cache for another reason.
+	Remove(item K)
 }
 
-type DoublyLinkedList struct {
-	head *DoubleLinkNode
-	tail *DoubleLinkNode
-	size int
+// victimPeeker is implemented by eviction policies that can report the next
+// key to evict without evicting it.
+type victimPeeker[K comparable] interface {
+	Victim() (K, bool)
 }
 
-func (dll *DoublyLinkedList) RemoveNode(node *DoubleLinkNode) {
-	if node == nil {
-		return
-	}
+// TypedAdmissionPolicy decides whether a new key may displace a resident one
+// when the cache is full.
+type TypedAdmissionPolicy[K comparable] interface {
+	// Record notes an access to key.
+	Record(key K)
+	// Admit reports whether candidate should be added in place of victim.
+	Admit(candidate, victim K) bool
+}
 
-	if node.Prev != nil {
-		node.Prev.Next = node.Next
-	} else {
-		dll.head = node.Next
-	}
+var ErrKeyNotFound = errors.New("key doesn't exist")
+
+// TypedCache is safe for concurrent use.
+type TypedCache[K comparable, V any] struct {
+	mu        sync.Mutex
+	store     TypedStorage[K, V]
+	policy    TypedEvictionPolicy[K]
+	admission TypedAdmissionPolicy[K]
+
+	config    CacheConfig
+	expiry    *expiryHeap[K]
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	onEvict       func(key K, value V, reason EvictionReason)
+	events        []evictionEvent[K, V] // Evictions to report on unlock.
+	evictionsDone chan struct{}
+	// asyncMu is taken before mu is released and held while queueing to
+	// asyncEvents, so evictions are queued in the order they happened.
+	asyncMu     sync.Mutex
+	asyncEvents []asyncEvictionEvent[K, V] // Nil unless config.AsyncEvictions is set.
+	asyncReady  chan struct{}              // Signalled when asyncEvents is appended to.
+	asyncDone   bool                       // Set once evictions are reported synchronously again.
+
+	stats cacheStats
+
+	tags     tagIndex[K]
+	prefixes *prefixIndex // Nil unless config.PrefixIndex is set.
+}
 
-	if node.Next != nil {
-		node.Next.Prev = node.Prev
-	} else {
-		dll.tail = node.Prev
+func NewCache(storage Storage, policy EvictionPolicy, opts ...CacheOption) *Cache {
+	return NewTypedCache(storage, policy, opts...)
+}
+
+func NewTypedCache[K comparable, V any](storage TypedStorage[K, V], policy TypedEvictionPolicy[K], opts ...CacheOption) *TypedCache[K, V] {
+	c := CacheConfig{Clock: time.Now, SnapshotCodec: GobCodec{}}
+	for _, opt := range opts {
+		opt(&c)
 	}
 
-	node.Prev = nil
-	node.Next = nil
+	cache := &TypedCache[K, V]{
+		store:  storage,
+		policy: policy,
+		config: c,
+		expiry: newExpiryHeap[K](),
+		stop:   make(chan struct{}),
+	}
+	if c.PrefixIndex {
+		cache.prefixes = new(prefixIndex)
+	}
+	// Storage that outlives the process, such as DiskStorage, may already
+	// hold keys the policy must know about to evict them.
+	for _, key := range storage.Keys() {
+		policy.ItemAccessed(key)
+		cache.indexLocked(key, nil)
+	}
+	if c.JanitorInterval > 0 {
+		go cache.janitor(c.JanitorInterval)
+	}
+	if c.AsyncEvictions > 0 {
+		cache.asyncEvents = make([]asyncEvictionEvent[K, V], 0, c.AsyncEvictions)
+		cache.asyncReady = make(chan struct{}, 1)
+		cache.evictionsDone = make(chan struct{})
+		go cache.deliverEvictions()
+	}
 
-	dll.size--
+	return cache
 }
 
-func (dll *DoublyLinkedList) AddItemToHead(item any) *DoubleLinkNode {
-	node := &DoubleLinkNode{Val: item}
-	dll.AddToHead(node)
-	return node
+// SetAdmissionPolicy makes Put consult policy before evicting each key to make
+// room for a new one. A rejected Put returns nil without adding the key, though
+// keys evicted before the rejection stay evicted. Updates to keys already in
+// the cache are always admitted.
+// Admission only applies if the eviction policy has a Victim() (K, bool)
+// method, as all the policies in this package do.
+func (c *TypedCache[K, V]) SetAdmissionPolicy(policy TypedAdmissionPolicy[K]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.admission = policy
 }
 
-func (dll *DoublyLinkedList) AddToHead(node *DoubleLinkNode) {
-	if dll.head == nil {
-		dll.head = node
-		dll.tail = node
-	} else {
-		node.Next = dll.head
-		dll.head.Prev = node
-		dll.head = node
-	}
+func (c *TypedCache[K, V]) Get(key K) (V, error) {
+	val, _, err := c.getWithExpiry(key)
+	return val, err
 }
 
-func (dll *DoublyLinkedList) RemoveTail() *DoubleLinkNode {
-	if dll.tail == nil {
-		return nil
-	}
+// getWithExpiry is Get that also returns when key expires and how long its
+// value took to load. The expiry time is zero if the key never expires.
+func (c *TypedCache[K, V]) getWithExpiry(key K) (V, expiryItem[K], error) {
+	c.mu.Lock()
+	defer c.unlock()
 
-	prevTail := dll.tail
-	if dll.tail.Prev != nil {
-		dll.tail = dll.tail.Prev
-		dll.tail.Next = nil
-	} else {
-		dll.head = nil
-		dll.tail = nil
+	if c.admission != nil {
+		c.admission.Record(key)
 	}
-	prevTail.Prev = nil
 
-	dll.size--
+	val, ok := c.store.Get(key)
+	if ok && c.expiredLocked(key) {
+		c.removeLocked(key, EvictionExpired)
+		ok = false
+	}
+	if !ok {
+		c.stats.misses.Add(1)
+		var zero V
+		return zero, expiryItem[K]{}, ErrKeyNotFound
+	}
+	c.stats.hits.Add(1)
+	c.policy.ItemAccessed(key)
 
-	return prevTail
+	var expiry expiryItem[K]
+	if item, ok := c.expiry.lut[key]; ok {
+		expiry = *item
+	}
+	return val, expiry, nil
 }
 
-type MemoryStorage struct {
-	store  map[any]any
-	cap    int
-	size   int
-	isFull bool
+// Put adds key with the default TTL, if any.
+func (c *TypedCache[K, V]) Put(key K, value V, opts ...PutOption) error {
+	return c.PutWithTTL(key, value, c.config.DefaultTTL, opts...)
 }
 
-func NewMemoryStorage(cap int) *MemoryStorage {
-	return &MemoryStorage{
-		cap:   cap,
-		store: make(map[any]any, cap),
+// PutWithTTL adds key so that it expires after ttl. A ttl of zero or less means
+// the key never expires.
+func (c *TypedCache[K, V]) PutWithTTL(key K, value V, ttl time.Duration, opts ...PutOption) error {
+	var pc PutConfig
+	for _, opt := range opts {
+		opt(&pc)
 	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	return c.putLocked(key, value, ttl, pc)
 }
 
-var ErrStorageFull = errors.New("storage is full")
+func (c *TypedCache[K, V]) putLocked(key K, value V, ttl time.Duration, pc PutConfig) error {
+	if c.admission != nil {
+		c.admission.Record(key)
+	}
 
-func (m *MemoryStorage) Add(key any, value any) error {
-	if m.isFull {
-		return ErrStorageFull
+	old, replaced := c.store.Get(key)
+	err := c.store.Add(key, value)
+	if errors.Is(err, ErrStorageFull) && c.removeExpiredLocked() > 0 {
+		err = c.store.Add(key, value)
+	}
+	// Evict until the new entry fits, which may take several evictions if
+	// entries have different costs. A key already in the cache was admitted
+	// before, so an update isn't held back by the admission policy and
+	// can't leave the old value in place.
+	for errors.Is(err, ErrStorageFull) {
+		if !c.hasVictim() {
+			break
+		}
+		if !replaced && !c.admit(key) {
+			return nil
+		}
+		victim := c.policy.Evict()
+		c.dropLocked(victim, EvictionCapacity)
+		if victim == key {
+			replaced = false
+		}
+		err = c.store.Add(key, value)
+	}
+	if err != nil {
+		return fmt.Errorf("adding key %v: %w", key, err)
 	}
+	c.policy.ItemAccessed(key)
+	if replaced {
+		c.recordLocked(key, old, EvictionReplaced)
+	}
+	c.indexLocked(key, pc.Tags)
 
-	m.size++
-	if m.size == m.cap {
-		m.isFull = true
+	if ttl > 0 {
+		c.expiry.set(key, c.config.Clock().Add(ttl))
+		c.expiry.lut[key].loadTime = pc.loadTime
+	} else {
+		c.expiry.remove(key)
 	}
-	m.store[key] = value
 
 	return nil
 }
 
-func (m *MemoryStorage) Remove(key any) {
-	delete(m.store, key)
-}
+// peek returns key's value without counting as an access: the eviction policy,
+// admission policy and hit and miss counts are left alone.
+func (c *TypedCache[K, V]) peek(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.unlock()
 
-func (m *MemoryStorage) Get(key any) (any, bool) {
-	val, ok := m.store[key]
+	val, ok := c.store.Get(key)
+	if ok && c.expiredLocked(key) {
+		c.removeLocked(key, EvictionExpired)
+		var zero V
+		return zero, false
+	}
 	return val, ok
 }
 
-type Storage interface {
-	Add(key any, value any) error
-	Remove(key any)
-	Get(key any) (any, bool)
-}
-
-type LRUEvictionPolicy struct {
-	dll *DoublyLinkedList
-	lut map[any]*DoubleLinkNode
-}
+// peekExpiry reports whether key is cached and when it expires, or the zero
+// time if it never does. Unlike Get, it doesn't count as an access.
+func (c *TypedCache[K, V]) peekExpiry(key K) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.unlock()
 
-func NewLRUEvictionPolicy() *LRUEvictionPolicy {
-	return &LRUEvictionPolicy{
-		dll: new(DoublyLinkedList),
-		lut: make(map[any]*DoubleLinkNode),
+	if _, ok := c.store.Get(key); !ok {
+		return time.Time{}, false
 	}
-}
-
-func (l *LRUEvictionPolicy) ItemAccessed(item any) {
-	if node, ok := l.lut[item]; ok {
-		l.dll.RemoveNode(node)
-		l.dll.AddToHead(node)
-	} else {
-		node := l.dll.AddItemToHead(item)
-		l.lut[item] = node
+	if c.expiredLocked(key) {
+		c.removeLocked(key, EvictionExpired)
+		return time.Time{}, false
 	}
+	if item, ok := c.expiry.lut[key]; ok {
+		return item.at, true
+	}
+	return time.Time{}, true
 }
 
-func (l *LRUEvictionPolicy) Evict(item any) any {
-	return l.dll.RemoveTail()
-}
-
-type EvictionPolicy interface {
-	ItemAccessed(item any)
-	Evict() any
-}
+// Delete removes key. It returns ErrKeyNotFound if key isn't cached or has
+// expired.
+func (c *TypedCache[K, V]) Delete(key K) error {
+	c.mu.Lock()
+	defer c.unlock()
 
-type Cache struct {
-	store  Storage
-	policy EvictionPolicy
+	if _, ok := c.store.Get(key); !ok {
+		return ErrKeyNotFound
+	}
+	if c.expiredLocked(key) {
+		c.removeLocked(key, EvictionExpired)
+		return ErrKeyNotFound
+	}
+	c.removeLocked(key, EvictionDeleted)
+	return nil
 }
 
-func NewCache(storage Storage, policy EvictionPolicy) *Cache {
-	cache := Cache{
-		store:  storage,
-		policy: policy,
+// hasVictim reports whether the eviction policy has a key to evict. Policies
+// that can't tell are assumed to.
+func (c *TypedCache[K, V]) hasVictim() bool {
+	peeker, ok := c.policy.(victimPeeker[K])
+	if !ok {
+		return true
 	}
-
-	return &cache
+	_, ok = peeker.Victim()
+	return ok
 }
 
-func (c *Cache) Get(key any) (any, error) {
-	val, ok := c.store.Get(key)
+// admit reports whether key may displace the eviction policy's next victim.
+func (c *TypedCache[K, V]) admit(key K) bool {
+	if c.admission == nil {
+		return true
+	}
+	peeker, ok := c.policy.(victimPeeker[K])
 	if !ok {
-		return nil, fmt.Errorf("key doesn't exist")
+		return true
 	}
-	c.policy.ItemAccessed(key)
+	victim, ok := peeker.Victim()
+	return !ok || c.admission.Admit(key, victim)
+}
 
-	return val, nil
+// expiredLocked reports whether key has expired, only reading the clock for
+// keys with a TTL.
+func (c *TypedCache[K, V]) expiredLocked(key K) bool {
+	item, ok := c.expiry.lut[key]
+	return ok && !item.at.After(c.config.Clock())
 }
 
-func (c *Cache) Put(key, value any) error {
-	c.policy.ItemAccessed(key)
-	err := c.store.Add(key, value)
-	if err != nil {
-		if errors.Is(err, ErrStorageFull) {
-			c.policy.Evict()
-			if err = c.store.Add(key, value); err != nil {
-				return fmt.Errorf("unkown error")
-			}
-		}
-	}
+// removeLocked removes key from the storage, eviction policy and expiry heap,
+// and records why for the OnEvict hook.
+func (c *TypedCache[K, V]) removeLocked(key K, reason EvictionReason) {
+	c.dropLocked(key, reason)
+	c.policy.Remove(key)
+}
 
-	return nil
+// dropLocked is removeLocked for a key the eviction policy has already let
+// go of. Telling the policy again could erase history it keeps on purpose,
+// such as ARC's ghost entries.
+func (c *TypedCache[K, V]) dropLocked(key K, reason EvictionReason) {
+	if val, ok := c.store.Get(key); ok {
+		c.recordLocked(key, val, reason)
+	}
+	c.store.Remove(key)
+	c.expiry.remove(key)
+	c.unindexLocked(key)
 }
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"testing"
+)
+
+var (
+	_ EvictionPolicy               = (*LRUEvictionPolicy)(nil)
+	_ TypedEvictionPolicy[string]  = (*TypedLRUEvictionPolicy[string])(nil)
+	_ Storage                      = (*MemoryStorage)(nil)
+	_ TypedStorage[string, []byte] = (*TypedMemoryStorage[string, []byte])(nil)
+)
+
+func TestLRUEvictionPolicyOrder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		accesses []any
+		want     []any
+	}{
+		{"insertion order", []any{1, 2, 3}, []any{1, 2, 3}},
+		{"reaccess moves to front", []any{1, 2, 3, 1}, []any{2, 3, 1}},
+		{"repeated access", []any{1, 1, 1, 2}, []any{1, 2}},
+		{"interleaved", []any{1, 2, 3, 2, 1, 4, 3}, []any{2, 1, 4, 3}},
+		{"empty", nil, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lru := NewLRUEvictionPolicy()
+			for _, key := range tc.accesses {
+				lru.ItemAccessed(key)
+			}
+
+			for _, want := range tc.want {
+				if got := lru.Evict(); got != want {
+					t.Fatalf("Evict: got %v, want %v", got, want)
+				}
+			}
+			if got := lru.Evict(); got != nil {
+				t.Errorf("Evict on empty policy: got %v, want nil", got)
+			}
+		})
+	}
+}
+
+func TestLRUEvictionPolicyReaddAfterEvict(t *testing.T) {
+	lru := NewLRUEvictionPolicy()
+	lru.ItemAccessed("a")
+	lru.ItemAccessed("b")
+
+	if got := lru.Evict(); got != "a" {
+		t.Fatalf("Evict: got %v, want a", got)
+	}
+	// An evicted key is tracked afresh when accessed again.
+	lru.ItemAccessed("a")
+	if got := lru.Evict(); got != "b" {
+		t.Fatalf("Evict: got %v, want b", got)
+	}
+	if got := lru.Evict(); got != "a" {
+		t.Fatalf("Evict: got %v, want a", got)
+	}
+}
+
+func TestCacheLRUEviction(t *testing.T) {
+	type op struct {
+		put bool // Get otherwise.
+		key string
+	}
+
+	testCases := []struct {
+		name        string
+		capacity    int
+		ops         []op
+		wantPresent []string
+		wantEvicted []string
+	}{
+		{
+			name:        "evicts oldest put",
+			capacity:    2,
+			ops:         []op{{true, "a"}, {true, "b"}, {true, "c"}},
+			wantPresent: []string{"b", "c"},
+			wantEvicted: []string{"a"},
+		},
+		{
+			name:        "get refreshes recency",
+			capacity:    2,
+			ops:         []op{{true, "a"}, {true, "b"}, {false, "a"}, {true, "c"}},
+			wantPresent: []string{"a", "c"},
+			wantEvicted: []string{"b"},
+		},
+		{
+			name:        "capacity of one",
+			capacity:    1,
+			ops:         []op{{true, "a"}, {true, "b"}, {true, "c"}},
+			wantPresent: []string{"c"},
+			wantEvicted: []string{"a", "b"},
+		},
+		{
+			name:     "repeated evictions",
+			capacity: 3,
+			ops: []op{
+				{true, "a"}, {true, "b"}, {true, "c"},
+				{false, "a"}, {true, "d"}, {false, "c"}, {true, "e"},
+			},
+			wantPresent: []string{"c", "d", "e"},
+			wantEvicted: []string{"a", "b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewCache(NewMemoryStorage(tc.capacity), NewLRUEvictionPolicy())
+			for _, o := range tc.ops {
+				if o.put {
+					if err := c.Put(o.key, o.key+"-value"); err != nil {
+						t.Fatalf("Put(%q) failed: %v", o.key, err)
+					}
+					continue
+				}
+				if _, err := c.Get(o.key); err != nil {
+					t.Fatalf("Get(%q) failed: %v", o.key, err)
+				}
+			}
+
+			for _, key := range tc.wantPresent {
+				got, err := c.Get(key)
+				if err != nil {
+					t.Errorf("Get(%q) failed: %v", key, err)
+					continue
+				}
+				if got != key+"-value" {
+					t.Errorf("Get(%q): got %v, want %v", key, got, key+"-value")
+				}
+			}
+			for _, key := range tc.wantEvicted {
+				if _, err := c.Get(key); err == nil {
+					t.Errorf("Get(%q): got nil error for evicted key", key)
+				}
+			}
+		})
+	}
+}
+
+func TestTypedCacheLRUEviction(t *testing.T) {
+	c := NewTypedCache[int, string](NewTypedMemoryStorage[int, string](2), NewTypedLRUEvictionPolicy[int]())
+
+	for i := 1; i <= 3; i++ {
+		if err := c.Put(i*1000, "value"); err != nil {
+			t.Fatalf("Put(%d) failed: %v", i*1000, err)
+		}
+	}
+
+	if got, err := c.Get(1000); err == nil {
+		t.Errorf("Get(1000): got %q for evicted key", got)
+	}
+	for _, key := range []int{2000, 3000} {
+		got, err := c.Get(key)
+		if err != nil || got != "value" {
+			t.Errorf("Get(%d): got %q, %v, want value, nil", key, got, err)
+		}
+	}
+}
+
+const benchCacheSize = 1024
+
+// BenchmarkCachePut compares Put on the any-based Cache with TypedCache. Keys
+// are larger than 255 so they can't use the runtime's preallocated boxes.
+func BenchmarkCachePut(b *testing.B) {
+	b.Run("any", func(b *testing.B) {
+		c := NewCache(NewMemoryStorage(benchCacheSize), NewLRUEvictionPolicy())
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			c.Put(n+256, n+256)
+		}
+	})
+	b.Run("typed", func(b *testing.B) {
+		c := NewTypedCache[int, int](NewTypedMemoryStorage[int, int](benchCacheSize), NewTypedLRUEvictionPolicy[int]())
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			c.Put(n+256, n+256)
+		}
+	})
+}
+
+// BenchmarkCacheGet compares Get hits on the any-based Cache with TypedCache.
+func BenchmarkCacheGet(b *testing.B) {
+	b.Run("any", func(b *testing.B) {
+		c := NewCache(NewMemoryStorage(benchCacheSize), NewLRUEvictionPolicy())
+		for i := 0; i < benchCacheSize; i++ {
+			c.Put(i+256, i+256)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			c.Get(n%benchCacheSize + 256)
+		}
+	})
+	b.Run("typed", func(b *testing.B) {
+		c := NewTypedCache[int, int](NewTypedMemoryStorage[int, int](benchCacheSize), NewTypedLRUEvictionPolicy[int]())
+		for i := 0; i < benchCacheSize; i++ {
+			c.Put(i+256, i+256)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			c.Get(n%benchCacheSize + 256)
+		}
+	})
+}
+
+func TestEvictionPolicyRemove(t *testing.T) {
+	for _, p := range tracePolicies {
+		t.Run(p.name, func(t *testing.T) {
+			policy := p.new(10)
+			for _, key := range []int{1, 2, 3} {
+				policy.ItemAccessed(key)
+			}
+			policy.Remove(2)
+			policy.Remove(42)
+
+			for _, want := range []int{1, 3} {
+				if got := policy.Evict(); got != want {
+					t.Fatalf("Evict: got %d, want %d", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDoubleLinkNodeKeepsKey(t *testing.T) {
+	var dll DoublyLinkedList
+	dll.AddToHead(&DoubleLinkNode{Key: 1, Val: "a"})
+	if node := dll.RemoveTail(); node.Key != 1 || node.Val != "a" {
+		t.Errorf("RemoveTail: got key %d, value %v, want 1, a", node.Key, node.Val)
+	}
+}
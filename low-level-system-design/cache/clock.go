@@ -0,0 +1,142 @@
+package cache
+
+// ClockEvictionPolicy tracks arbitrary keys.
+type ClockEvictionPolicy = TypedClockEvictionPolicy[any]
+
+// TypedClockEvictionPolicy implements CLOCK, also known as second chance, an
+// approximation of LRU. Keys sit in a ring buffer with a referenced bit that
+// an access sets. To evict, a hand sweeps the ring, clearing set bits and
+// giving those keys a second chance, until it finds a key whose bit is clear.
+//
+// An access only sets a bit, and a key costs one slot in a slice rather than
+// a linked list node with two pointers, so it uses less memory than
+// LRUEvictionPolicy. A new key starts with its bit clear, so keys that are
+// never read again go first.
+type TypedClockEvictionPolicy[K comparable] struct {
+	ring []clockSlot[K]
+	lut  map[K]int // Index of each key's slot.
+	free []int     // Slots emptied by Remove, for reuse.
+	hand int
+}
+
+type clockSlot[K comparable] struct {
+	key        K
+	referenced bool
+	used       bool
+}
+
+func NewClockEvictionPolicy(capacity int) *ClockEvictionPolicy {
+	return NewTypedClockEvictionPolicy[any](capacity)
+}
+
+// NewTypedClockEvictionPolicy creates a CLOCK policy with room for capacity
+// keys. The ring grows if more are tracked.
+func NewTypedClockEvictionPolicy[K comparable](capacity int) *TypedClockEvictionPolicy[K] {
+	return &TypedClockEvictionPolicy[K]{
+		ring: make([]clockSlot[K], 0, capacity),
+		lut:  make(map[K]int, capacity),
+	}
+}
+
+func (c *TypedClockEvictionPolicy[K]) ItemAccessed(item K) {
+	if i, ok := c.lut[item]; ok {
+		c.ring[i].referenced = true
+		return
+	}
+
+	slot := clockSlot[K]{key: item, used: true}
+	if n := len(c.free); n > 0 {
+		i := c.free[n-1]
+		c.free = c.free[:n-1]
+		c.ring[i] = slot
+		c.lut[item] = i
+		return
+	}
+	c.ring = append(c.ring, slot)
+	c.lut[item] = len(c.ring) - 1
+}
+
+// Victim returns the key Evict would remove without removing it. It moves
+// the hand up to that key, clearing the bits it passes as Evict would, so the
+// sweep is done once per eviction however often Victim is called.
+func (c *TypedClockEvictionPolicy[K]) Victim() (K, bool) {
+	if !c.sweep() {
+		var zero K
+		return zero, false
+	}
+	return c.ring[c.hand].key, true
+}
+
+// Evict removes the first key past the hand whose referenced bit is clear,
+// clearing the bits it passes, and returns it, or the zero value if no keys
+// are tracked.
+func (c *TypedClockEvictionPolicy[K]) Evict() K {
+	if !c.sweep() {
+		var zero K
+		return zero
+	}
+	key := c.ring[c.hand].key
+	c.clear(c.hand)
+	c.hand = (c.hand + 1) % len(c.ring)
+	return key
+}
+
+// sweep advances the hand to the first unreferenced key, clearing the bits
+// it passes, and reports whether any keys are tracked. It stops within two
+// turns of the ring, and returns at once if the hand is already on an
+// unreferenced key.
+func (c *TypedClockEvictionPolicy[K]) sweep() bool {
+	if len(c.lut) == 0 {
+		return false
+	}
+	for {
+		slot := &c.ring[c.hand]
+		if slot.used {
+			if !slot.referenced {
+				return true
+			}
+			slot.referenced = false
+		}
+		c.hand = (c.hand + 1) % len(c.ring)
+	}
+}
+
+func (c *TypedClockEvictionPolicy[K]) Remove(item K) {
+	if i, ok := c.lut[item]; ok {
+		c.clear(i)
+	}
+}
+
+func (c *TypedClockEvictionPolicy[K]) clear(i int) {
+	delete(c.lut, c.ring[i].key)
+	c.ring[i] = clockSlot[K]{}
+	c.free = append(c.free, i)
+}
+
+// exportState lists the keys in sweep order from the hand, with a count of 1
+// for those whose referenced bit is set.
+func (c *TypedClockEvictionPolicy[K]) exportState() policyState[K] {
+	s := policyState[K]{Keys: make([]policyKey[K], 0, len(c.lut))}
+	for n := 0; n < len(c.ring); n++ {
+		slot := c.ring[(c.hand+n)%len(c.ring)]
+		if !slot.used {
+			continue
+		}
+		k := policyKey[K]{Key: slot.key}
+		if slot.referenced {
+			k.Count = 1
+		}
+		s.Keys = append(s.Keys, k)
+	}
+	return s
+}
+
+// importState sets the keys' referenced bits. They keep their place in the
+// ring, which for a restored cache is the order they were added in.
+func (c *TypedClockEvictionPolicy[K]) importState(s policyState[K]) {
+	for _, k := range s.Keys {
+		if i, ok := c.lut[k.Key]; ok {
+			c.ring[i].referenced = k.Count > 0
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var (
+	_ EvictionPolicy              = (*ClockEvictionPolicy)(nil)
+	_ TypedEvictionPolicy[string] = (*TypedClockEvictionPolicy[string])(nil)
+)
+
+func TestClockEvictionPolicySecondChance(t *testing.T) {
+	c := NewTypedClockEvictionPolicy[int](4)
+	for _, key := range []int{1, 2, 3} {
+		c.ItemAccessed(key)
+	}
+	c.ItemAccessed(1)
+
+	// 1 is referenced, so the hand clears its bit and passes it by.
+	for _, want := range []int{2, 3, 1} {
+		if got := c.Evict(); got != want {
+			t.Fatalf("Evict: got %d, want %d", got, want)
+		}
+	}
+	if got, ok := c.Victim(); ok {
+		t.Errorf("Victim on empty policy: got %d, true", got)
+	}
+	if got := c.Evict(); got != 0 {
+		t.Errorf("Evict on empty policy: got %d, want 0", got)
+	}
+
+	// With every key referenced, the hand goes round once and takes the
+	// first.
+	c = NewTypedClockEvictionPolicy[int](4)
+	for _, key := range []int{4, 5, 6, 4, 5, 6} {
+		c.ItemAccessed(key)
+	}
+	if got := c.Evict(); got != 4 {
+		t.Errorf("Evict with every key referenced: got %d, want 4", got)
+	}
+}
+
+func TestClockEvictionPolicyReusesSlots(t *testing.T) {
+	c := NewTypedClockEvictionPolicy[int](4)
+	for _, key := range []int{1, 2, 3, 4} {
+		c.ItemAccessed(key)
+	}
+	c.Remove(2)
+	c.Evict()
+	c.ItemAccessed(5)
+	c.ItemAccessed(6)
+
+	if n := len(c.ring); n != 4 {
+		t.Errorf("ring grew to %d slots for 4 keys", n)
+	}
+	if n := len(c.lut); n != 4 {
+		t.Errorf("tracking %d keys, want 4", n)
+	}
+}
+
+func TestClockEvictionPolicyVictimMatchesEvict(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	c := NewTypedClockEvictionPolicy[int](16)
+	for i := 0; i < 10_000; i++ {
+		switch key := r.Intn(32); r.Intn(4) {
+		case 0:
+			victim, ok := c.Victim()
+			if got := c.Evict(); ok && got != victim {
+				t.Fatalf("step %d: Evict got %d, Victim said %d", i, got, victim)
+			}
+		case 1:
+			c.Remove(key)
+		default:
+			c.ItemAccessed(key)
+		}
+	}
+}
+
+// BenchmarkClockEvictionPolicyRejectedPut measures a cache-aside workload on a
+// full cache: reads keep every resident key referenced, and TinyLFU turns the
+// new keys away. Nothing is evicted, so the hand must not sweep the ring on
+// every Put.
+func BenchmarkClockEvictionPolicyRejectedPut(b *testing.B) {
+	c := NewTypedCache[int, int](NewTypedMemoryStorage[int, int](benchCacheSize), NewTypedClockEvictionPolicy[int](benchCacheSize))
+	c.SetAdmissionPolicy(NewTypedTinyLFU[int](benchCacheSize))
+	for i := 0; i < benchCacheSize; i++ {
+		c.Put(i, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		c.Get(n % benchCacheSize)
+		c.Put(benchCacheSize+n, n)
+	}
+}
@@ -0,0 +1,512 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Versioned is a value as replicas store it. The highest version of a key
+// wins; a deleted key is kept as a tombstone so an older write can't bring
+// it back.
+type Versioned[V any] struct {
+	Value   V
+	Version uint64
+	Deleted bool
+}
+
+// ClusterNode is one member of a cluster, holding its share of the keys in a
+// local cache. It is safe for concurrent use.
+//
+// Tombstones for deleted keys are kept apart from the cache, so capacity
+// pressure can't evict them, and are dropped after the node's TombstoneTTL.
+// Once a tombstone is gone, a write older than the delete that reaches the
+// node, from a lagging replica or a slow client, brings the key back.
+type ClusterNode[K comparable, V any] struct {
+	mu    sync.Mutex
+	cache *TypedCache[K, Versioned[V]]
+	ttl   time.Duration
+
+	tombstones map[K]tombstone
+	expiries   []tombstoneExpiry[K] // Oldest first, to sweep tombstones.
+}
+
+// tombstone records the version a key was deleted at.
+type tombstone struct {
+	version   uint64
+	expiresAt time.Time
+}
+
+type tombstoneExpiry[K comparable] struct {
+	key       K
+	expiresAt time.Time
+}
+
+// ClusterNodeConfig is needed to create a new cluster node.
+type ClusterNodeConfig struct {
+	// TombstoneTTL is how long the node remembers a deleted key. It should
+	// be longer than a stale write can linger, such as a replica being down
+	// before read repair reaches it.
+	// Default is 10 minutes.
+	TombstoneTTL time.Duration
+}
+
+// ClusterNodeOption is used to configure a new cluster node.
+type ClusterNodeOption func(*ClusterNodeConfig)
+
+// WithTombstoneTTL sets how long the node remembers a deleted key.
+func WithTombstoneTTL(ttl time.Duration) ClusterNodeOption {
+	return func(c *ClusterNodeConfig) {
+		c.TombstoneTTL = ttl
+	}
+}
+
+// NewClusterNode creates a node that stores its keys in cache. Tombstones
+// expire by the cache's clock.
+func NewClusterNode[K comparable, V any](cache *TypedCache[K, Versioned[V]], opts ...ClusterNodeOption) *ClusterNode[K, V] {
+	const defaultTombstoneTTL = 10 * time.Minute
+	c := ClusterNodeConfig{TombstoneTTL: defaultTombstoneTTL}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &ClusterNode[K, V]{
+		cache:      cache,
+		ttl:        c.TombstoneTTL,
+		tombstones: make(map[K]tombstone),
+	}
+}
+
+// Get returns the node's copy of key, or the zero Versioned if it has none.
+func (n *ClusterNode[K, V]) Get(key K) Versioned[V] {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if t, ok := n.tombstoneLocked(key); ok {
+		return Versioned[V]{Version: t.version, Deleted: true}
+	}
+	v, _ := n.cache.Get(key)
+	return v
+}
+
+// Put stores v unless the node already has a newer version of key. A deleted
+// v removes key from the cache and leaves a tombstone in its place.
+func (n *ClusterNode[K, V]) Put(key K, v Versioned[V]) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.sweepLocked()
+	if t, ok := n.tombstoneLocked(key); ok {
+		if t.version >= v.Version {
+			return nil
+		}
+	} else if cur, err := n.cache.Get(key); err == nil && cur.Version >= v.Version {
+		return nil
+	}
+
+	if v.Deleted {
+		expiresAt := n.cache.config.Clock().Add(n.ttl)
+		n.tombstones[key] = tombstone{version: v.Version, expiresAt: expiresAt}
+		n.expiries = append(n.expiries, tombstoneExpiry[K]{key: key, expiresAt: expiresAt})
+		if err := n.cache.Delete(key); err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return err
+		}
+		return nil
+	}
+	delete(n.tombstones, key)
+	return n.cache.Put(key, v)
+}
+
+// tombstoneLocked returns key's tombstone if it hasn't expired.
+func (n *ClusterNode[K, V]) tombstoneLocked(key K) (tombstone, bool) {
+	t, ok := n.tombstones[key]
+	if !ok || !n.cache.config.Clock().Before(t.expiresAt) {
+		return tombstone{}, false
+	}
+	return t, true
+}
+
+// sweepLocked drops expired tombstones. An expiry whose key has since been
+// deleted again, or written, no longer matches the map and is just skipped.
+func (n *ClusterNode[K, V]) sweepLocked() {
+	now := n.cache.config.Clock()
+	var i int
+	for ; i < len(n.expiries) && !now.Before(n.expiries[i].expiresAt); i++ {
+		e := n.expiries[i]
+		if t, ok := n.tombstones[e.key]; ok && t.expiresAt.Equal(e.expiresAt) {
+			delete(n.tombstones, e.key)
+		}
+	}
+	n.expiries = n.expiries[i:]
+}
+
+// Transport carries requests from a cluster client to nodes.
+type Transport[K comparable, V any] interface {
+	Get(node string, key K) (Versioned[V], error)
+	Put(node string, key K, v Versioned[V]) error
+}
+
+// ErrNodeUnreachable is returned by a transport that can't reach a node.
+var ErrNodeUnreachable = errors.New("node unreachable")
+
+// LocalNetwork connects nodes and clients in one process, for testing. Nodes
+// can be taken down and the network split into partitions. It is safe for
+// concurrent use.
+type LocalNetwork[K comparable, V any] struct {
+	mu     sync.RWMutex
+	nodes  map[string]*ClusterNode[K, V]
+	down   map[string]bool
+	groups map[string]int // Partition of each name, if split.
+}
+
+func NewLocalNetwork[K comparable, V any]() *LocalNetwork[K, V] {
+	return &LocalNetwork[K, V]{
+		nodes: make(map[string]*ClusterNode[K, V]),
+		down:  make(map[string]bool),
+	}
+}
+
+// Join adds node to the network as name.
+func (n *LocalNetwork[K, V]) Join(name string, node *ClusterNode[K, V]) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nodes[name] = node
+}
+
+// SetDown makes name unreachable, or reachable again.
+func (n *LocalNetwork[K, V]) SetDown(name string, down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.down[name] = down
+}
+
+// Partition splits the network so names in different groups can't reach each
+// other. Names in no group can still reach, and be reached by, everyone.
+func (n *LocalNetwork[K, V]) Partition(groups ...[]string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.groups = make(map[string]int)
+	for i, group := range groups {
+		for _, name := range group {
+			n.groups[name] = i
+		}
+	}
+}
+
+// Heal undoes Partition.
+func (n *LocalNetwork[K, V]) Heal() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.groups = nil
+}
+
+// Transport returns the network as seen from the client called from.
+func (n *LocalNetwork[K, V]) Transport(from string) Transport[K, V] {
+	return &localTransport[K, V]{network: n, from: from}
+}
+
+func (n *LocalNetwork[K, V]) reach(from, to string) (*ClusterNode[K, V], error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	node, ok := n.nodes[to]
+	if !ok || n.down[to] {
+		return nil, fmt.Errorf("%w: %s", ErrNodeUnreachable, to)
+	}
+	fromGroup, fromSplit := n.groups[from]
+	toGroup, toSplit := n.groups[to]
+	if fromSplit && toSplit && fromGroup != toGroup {
+		return nil, fmt.Errorf("%w: %s is partitioned from %s", ErrNodeUnreachable, to, from)
+	}
+	return node, nil
+}
+
+type localTransport[K comparable, V any] struct {
+	network *LocalNetwork[K, V]
+	from    string
+}
+
+func (t *localTransport[K, V]) Get(node string, key K) (Versioned[V], error) {
+	n, err := t.network.reach(t.from, node)
+	if err != nil {
+		return Versioned[V]{}, err
+	}
+	return n.Get(key), nil
+}
+
+func (t *localTransport[K, V]) Put(node string, key K, v Versioned[V]) error {
+	n, err := t.network.reach(t.from, node)
+	if err != nil {
+		return err
+	}
+	return n.Put(key, v)
+}
+
+// ClusterConfig is needed to create a new cluster client.
+type ClusterConfig struct {
+	// Replicas is the number of nodes each key is stored on.
+	// Default is 3.
+	Replicas int
+	// ReadQuorum is the number of replicas that must answer a read. With
+	// ReadQuorum + WriteQuorum > Replicas every read sees the latest
+	// successful write.
+	// Default is a majority of Replicas.
+	ReadQuorum int
+	// WriteQuorum is the number of replicas that must accept a write.
+	// Default is a majority of Replicas.
+	WriteQuorum int
+	// VirtualNodes is the number of points each node has on the hash ring.
+	// Default is 64.
+	VirtualNodes int
+	// Clock is used to version writes.
+	// Default is time.Now.
+	Clock func() time.Time
+	// WriterID is embedded in the version of every write, so writes from
+	// different clients at the same instant never share a version and every
+	// replica settles them the same way. It should be unique among the
+	// clients; only the low 12 bits are used.
+	// Default is random.
+	WriterID uint64
+
+	writerIDSet bool
+}
+
+// ClusterOption is used to configure a new cluster client.
+type ClusterOption func(*ClusterConfig)
+
+// WithReplicas sets the number of nodes each key is stored on.
+func WithReplicas(n int) ClusterOption {
+	return func(c *ClusterConfig) {
+		c.Replicas = n
+	}
+}
+
+// WithQuorums sets the number of replicas that must answer reads and accept writes.
+func WithQuorums(read, write int) ClusterOption {
+	return func(c *ClusterConfig) {
+		c.ReadQuorum = read
+		c.WriteQuorum = write
+	}
+}
+
+// WithVirtualNodes sets the number of points each node has on the hash ring.
+func WithVirtualNodes(n int) ClusterOption {
+	return func(c *ClusterConfig) {
+		c.VirtualNodes = n
+	}
+}
+
+// WithWriterID sets the ID that breaks ties between the versions of this
+// client's writes and other clients'.
+func WithWriterID(id uint64) ClusterOption {
+	return func(c *ClusterConfig) {
+		c.WriterID = id
+		c.writerIDSet = true
+	}
+}
+
+// WithClusterClock sets the clock used to version writes.
+func WithClusterClock(clock func() time.Time) ClusterOption {
+	return func(c *ClusterConfig) {
+		c.Clock = clock
+	}
+}
+
+// ErrQuorumNotMet is returned when too few replicas answer a request.
+var ErrQuorumNotMet = errors.New("quorum not met")
+
+// Cluster is a client for a cache spread over many nodes. Keys are placed on
+// Replicas nodes by a consistent-hash ring, and reads and writes go to all of
+// them but only wait for a quorum. Conflicting writes are settled by version,
+// last writer wins with ties going to the higher WriterID, and a read repairs
+// replicas it finds out of date.
+//
+// Nodes added or removed later don't get or hand over existing keys; keys
+// whose replicas change are only found again once rewritten or read-repaired.
+// Cluster is safe for concurrent use.
+type Cluster[K comparable, V any] struct {
+	ring      *HashRing
+	transport Transport[K, V]
+	config    ClusterConfig
+	versions  *versionClock
+}
+
+// NewCluster creates a client that reaches nodes through transport.
+func NewCluster[K comparable, V any](nodes []string, transport Transport[K, V], opts ...ClusterOption) (*Cluster[K, V], error) {
+	const (
+		defaultReplicas     = 3
+		defaultVirtualNodes = 64
+	)
+	c := ClusterConfig{
+		Replicas:     defaultReplicas,
+		VirtualNodes: defaultVirtualNodes,
+		Clock:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if !c.writerIDSet {
+		c.WriterID = rand.Uint64()
+	}
+	majority := c.Replicas/2 + 1
+	if c.ReadQuorum == 0 {
+		c.ReadQuorum = majority
+	}
+	if c.WriteQuorum == 0 {
+		c.WriteQuorum = majority
+	}
+	if c.Replicas < 1 || c.ReadQuorum > c.Replicas || c.WriteQuorum > c.Replicas || c.ReadQuorum < 1 || c.WriteQuorum < 1 {
+		return nil, fmt.Errorf("invalid quorums: %d replicas, read quorum %d, write quorum %d", c.Replicas, c.ReadQuorum, c.WriteQuorum)
+	}
+
+	cl := &Cluster[K, V]{
+		ring:      NewHashRing(c.VirtualNodes),
+		transport: transport,
+		config:    c,
+		versions:  newVersionClock(c.Clock, c.WriterID),
+	}
+	for _, node := range nodes {
+		cl.ring.Add(node)
+	}
+	return cl, nil
+}
+
+// Ring returns the hash ring, for adding and removing nodes.
+func (c *Cluster[K, V]) Ring() *HashRing {
+	return c.ring
+}
+
+// replicaResult is one replica's answer to a request.
+type replicaResult[V any] struct {
+	node string
+	val  Versioned[V]
+	err  error
+}
+
+// fanOut sends req to every replica of key at once and collects answers until
+// quorum of them succeed or all have answered. Stragglers finish in the
+// background.
+func (c *Cluster[K, V]) fanOut(key K, quorum int, req func(node string) (Versioned[V], error)) ([]replicaResult[V], error) {
+	nodes := c.ring.Nodes(key, c.config.Replicas)
+	if len(nodes) < quorum {
+		return nil, fmt.Errorf("%w: %d nodes for a quorum of %d", ErrQuorumNotMet, len(nodes), quorum)
+	}
+
+	results := make(chan replicaResult[V], len(nodes))
+	for _, node := range nodes {
+		go func(node string) {
+			val, err := req(node)
+			results <- replicaResult[V]{node: node, val: val, err: err}
+		}(node)
+	}
+
+	var ok []replicaResult[V]
+	var lastErr error
+	for range nodes {
+		r := <-results
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		ok = append(ok, r)
+		if len(ok) == quorum {
+			return ok, nil
+		}
+	}
+	return ok, fmt.Errorf("%w: %d of %d replicas answered, last error: %v", ErrQuorumNotMet, len(ok), quorum, lastErr)
+}
+
+// Get returns the newest value for key among a read quorum of its replicas.
+func (c *Cluster[K, V]) Get(key K) (V, error) {
+	results, err := c.fanOut(key, c.config.ReadQuorum, func(node string) (Versioned[V], error) {
+		return c.transport.Get(node, key)
+	})
+	if err != nil {
+		var zero V
+		return zero, fmt.Errorf("getting key %v: %w", key, err)
+	}
+
+	latest := results[0].val
+	for _, r := range results[1:] {
+		if r.val.Version > latest.Version {
+			latest = r.val
+		}
+	}
+	// Later writes from this client are ordered after what it has read,
+	// even if its clock is behind the writer's.
+	c.versions.observe(latest.Version)
+	for _, r := range results {
+		if r.val.Version < latest.Version {
+			c.transport.Put(r.node, key, latest)
+		}
+	}
+
+	if latest.Version == 0 || latest.Deleted {
+		var zero V
+		return zero, ErrKeyNotFound
+	}
+	return latest.Value, nil
+}
+
+func (c *Cluster[K, V]) Put(key K, value V) error {
+	return c.write(key, Versioned[V]{Value: value, Version: c.versions.next()})
+}
+
+// Delete writes a tombstone for key.
+func (c *Cluster[K, V]) Delete(key K) error {
+	return c.write(key, Versioned[V]{Version: c.versions.next(), Deleted: true})
+}
+
+// write sends v to every replica of key and waits for a write quorum. A write
+// that misses the quorum may still have reached some replicas.
+func (c *Cluster[K, V]) write(key K, v Versioned[V]) error {
+	_, err := c.fanOut(key, c.config.WriteQuorum, func(node string) (Versioned[V], error) {
+		return v, c.transport.Put(node, key, v)
+	})
+	if err != nil {
+		return fmt.Errorf("writing key %v: %w", key, err)
+	}
+	return nil
+}
+
+// versionNodeBits is the number of low bits of a version that hold the ID of
+// the writer, above which is a timestamp in microseconds.
+const versionNodeBits = 12
+
+// versionClock hands out versions in the manner of a hybrid logical clock:
+// the timestamp part follows the clock, but never goes back and is pushed past
+// any version observed, so a version always follows those its writer has
+// seen. The low bits hold the writer's ID, so two writers never hand out the
+// same version, and concurrent writes are settled by ID.
+// It is safe for concurrent use.
+type versionClock struct {
+	mu    sync.Mutex
+	clock func() time.Time
+	node  uint64
+	last  uint64 // Timestamp part of the last version handed out or observed.
+}
+
+func newVersionClock(clock func() time.Time, node uint64) *versionClock {
+	return &versionClock{clock: clock, node: node & (1<<versionNodeBits - 1)}
+}
+
+func (v *versionClock) next() uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	ts := uint64(v.clock().UnixMicro())
+	if ts <= v.last {
+		ts = v.last + 1
+	}
+	v.last = ts
+	return ts<<versionNodeBits | v.node
+}
+
+// observe makes later versions follow version.
+func (v *versionClock) observe(version uint64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.last = max(v.last, version>>versionNodeBits)
+}
@@ -0,0 +1,297 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHashRingBalance(t *testing.T) {
+	r := NewHashRing(128)
+	nodes := []string{"n1", "n2", "n3", "n4"}
+	for _, n := range nodes {
+		r.Add(n)
+	}
+
+	const keys = 40_000
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		counts[r.Nodes(i, 1)[0]]++
+	}
+	for _, n := range nodes {
+		// A perfectly even ring gives each node a quarter of the keys.
+		if share := float64(counts[n]) / keys; share < 0.15 || share > 0.35 {
+			t.Errorf("node %s owns %.2f of the keys", n, share)
+		}
+	}
+}
+
+func TestHashRingRemoveMovesOnlyItsKeys(t *testing.T) {
+	r := NewHashRing(64)
+	for _, n := range []string{"n1", "n2", "n3", "n4"} {
+		r.Add(n)
+	}
+
+	const keys = 10_000
+	before := make([]string, keys)
+	for i := range before {
+		before[i] = r.Nodes(i, 1)[0]
+	}
+	r.Remove("n3")
+
+	for i, owner := range before {
+		got := r.Nodes(i, 1)[0]
+		if owner != "n3" && got != owner {
+			t.Fatalf("key %d moved from %s to %s, though %s is still up", i, owner, got, owner)
+		}
+		if got == "n3" {
+			t.Fatalf("key %d still placed on removed node", i)
+		}
+	}
+}
+
+func TestHashRingNodes(t *testing.T) {
+	r := NewHashRing(16)
+	if got := r.Nodes("k", 3); got != nil {
+		t.Errorf("Nodes on empty ring: got %v, want nil", got)
+	}
+	for _, n := range []string{"n1", "n2", "n3"} {
+		r.Add(n)
+	}
+
+	got := r.Nodes("k", 5)
+	slices.Sort(got)
+	if want := []string{"n1", "n2", "n3"}; !slices.Equal(got, want) {
+		t.Errorf("Nodes(k, 5): got %v, want every node once", got)
+	}
+	if got := r.Members(); !slices.Equal(got, []string{"n1", "n2", "n3"}) {
+		t.Errorf("Members: got %v", got)
+	}
+}
+
+// newTestNetwork starts n nodes named n1, n2, ... on a local network.
+func newTestNetwork(n int) (*LocalNetwork[string, int], []string) {
+	network := NewLocalNetwork[string, int]()
+	var names []string
+	for i := 1; i <= n; i++ {
+		name := fmt.Sprintf("n%d", i)
+		c := NewTypedCache[string, Versioned[int]](NewTypedMemoryStorage[string, Versioned[int]](1000), NewTypedLRUEvictionPolicy[string]())
+		network.Join(name, NewClusterNode(c))
+		names = append(names, name)
+	}
+	return network, names
+}
+
+func newTestCluster(t *testing.T, network *LocalNetwork[string, int], nodes []string, from string, opts ...ClusterOption) *Cluster[string, int] {
+	t.Helper()
+	c, err := NewCluster(nodes, network.Transport(from), opts...)
+	if err != nil {
+		t.Fatalf("NewCluster failed: %v", err)
+	}
+	return c
+}
+
+func TestClusterPutGet(t *testing.T) {
+	network, nodes := newTestNetwork(5)
+	c := newTestCluster(t, network, nodes, "client")
+
+	for i := 0; i < 100; i++ {
+		if err := c.Put(fmt.Sprint(i), i); err != nil {
+			t.Fatalf("Put(%d) failed: %v", i, err)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if got, err := c.Get(fmt.Sprint(i)); err != nil || got != i {
+			t.Errorf("Get(%d): got %d, %v", i, got, err)
+		}
+	}
+
+	c.Delete("7")
+	if _, err := c.Get("7"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get after Delete: got %v, want ErrKeyNotFound", err)
+	}
+	if _, err := c.Get("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get(missing): got %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestClusterReplication(t *testing.T) {
+	network, nodes := newTestNetwork(5)
+	c := newTestCluster(t, network, nodes, "client", WithQuorums(3, 3))
+	c.Put("k", 1)
+
+	var holders int
+	for _, n := range nodes {
+		if v, _ := network.Transport("test").Get(n, "k"); v.Version != 0 {
+			holders++
+		}
+	}
+	if holders != 3 {
+		t.Errorf("key stored on %d nodes, want 3", holders)
+	}
+}
+
+func TestClusterFailover(t *testing.T) {
+	network, nodes := newTestNetwork(5)
+	c := newTestCluster(t, network, nodes, "client")
+	replicas := c.Ring().Nodes("k", 3)
+
+	c.Put("k", 1)
+
+	// With R=W=2 of 3, losing one replica is fine for reads and writes.
+	network.SetDown(replicas[0], true)
+	if err := c.Put("k", 2); err != nil {
+		t.Fatalf("Put with one replica down: %v", err)
+	}
+	if got, err := c.Get("k"); err != nil || got != 2 {
+		t.Fatalf("Get with one replica down: got %d, %v, want 2, nil", got, err)
+	}
+
+	// Losing a second one is not.
+	network.SetDown(replicas[1], true)
+	if err := c.Put("k", 3); !errors.Is(err, ErrQuorumNotMet) {
+		t.Errorf("Put with two replicas down: got %v, want ErrQuorumNotMet", err)
+	}
+	if _, err := c.Get("k"); !errors.Is(err, ErrQuorumNotMet) {
+		t.Errorf("Get with two replicas down: got %v, want ErrQuorumNotMet", err)
+	}
+}
+
+func TestClusterConcurrentWritersAgree(t *testing.T) {
+	network, nodes := newTestNetwork(3)
+	clock := newFakeClock()
+	a := newTestCluster(t, network, nodes, "a", WithQuorums(1, 3), WithClusterClock(clock.Now), WithWriterID(1))
+	b := newTestCluster(t, network, nodes, "b", WithQuorums(1, 3), WithClusterClock(clock.Now), WithWriterID(2))
+
+	// Both write at the same instant; whatever order the replicas see the
+	// writes in, they settle on the writer with the higher ID.
+	for i := range 50 {
+		key := fmt.Sprint(i)
+		var wg sync.WaitGroup
+		for value, c := range []*Cluster[string, int]{a, b} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := c.Put(key, value); err != nil {
+					t.Errorf("Put(%s) failed: %v", key, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		for _, n := range nodes {
+			if v, _ := network.Transport("test").Get(n, key); v.Value != 1 {
+				t.Fatalf("%s on %s: got %d, want the higher writer's 1", key, n, v.Value)
+			}
+		}
+	}
+}
+
+func TestClusterWriteFollowsRead(t *testing.T) {
+	network, nodes := newTestNetwork(3)
+	ahead, behind := newFakeClock(), newFakeClock()
+	ahead.Advance(time.Hour)
+	a := newTestCluster(t, network, nodes, "a", WithClusterClock(ahead.Now), WithWriterID(2))
+	b := newTestCluster(t, network, nodes, "b", WithClusterClock(behind.Now), WithWriterID(1))
+
+	a.Put("k", 1)
+	// b's clock is an hour behind, but having read a's write, b's next write
+	// still supersedes it.
+	if got, err := b.Get("k"); err != nil || got != 1 {
+		t.Fatalf("b.Get: got %d, %v, want 1, nil", got, err)
+	}
+	b.Put("k", 2)
+	if got, err := a.Get("k"); err != nil || got != 2 {
+		t.Errorf("a.Get after b's write: got %d, %v, want 2, nil", got, err)
+	}
+}
+
+func TestClusterReadRepair(t *testing.T) {
+	network, nodes := newTestNetwork(3)
+	c := newTestCluster(t, network, nodes, "client", WithQuorums(3, 2))
+
+	c.Put("k", 1)
+	network.SetDown("n1", true)
+	c.Put("k", 2)
+	network.SetDown("n1", false)
+
+	// The read sees n1's stale copy and the newer one, and fixes n1.
+	if got, err := c.Get("k"); err != nil || got != 2 {
+		t.Fatalf("Get: got %d, %v, want 2, nil", got, err)
+	}
+	if v, _ := network.Transport("test").Get("n1", "k"); v.Value != 2 {
+		t.Errorf("n1 after read repair: got %d, want 2", v.Value)
+	}
+}
+
+func TestClusterPartition(t *testing.T) {
+	network, nodes := newTestNetwork(5)
+	majority := newTestCluster(t, network, nodes, "a", WithReplicas(5))
+	minority := newTestCluster(t, network, nodes, "b", WithReplicas(5))
+
+	majority.Put("k", 1)
+	network.Partition(
+		[]string{"a", "n1", "n2", "n3"},
+		[]string{"b", "n4", "n5"},
+	)
+
+	// Only the side with a majority of replicas can make progress.
+	if err := majority.Put("k", 2); err != nil {
+		t.Fatalf("Put on the majority side: %v", err)
+	}
+	if _, err := minority.Get("k"); !errors.Is(err, ErrQuorumNotMet) {
+		t.Errorf("Get on the minority side: got %v, want ErrQuorumNotMet", err)
+	}
+	if err := minority.Delete("other"); !errors.Is(err, ErrQuorumNotMet) {
+		t.Errorf("Delete on the minority side: got %v, want ErrQuorumNotMet", err)
+	}
+
+	// Once healed, the minority side reads what the majority wrote.
+	network.Heal()
+	if got, err := minority.Get("k"); err != nil || got != 2 {
+		t.Errorf("Get after healing: got %d, %v, want 2, nil", got, err)
+	}
+}
+
+func TestClusterInvalidQuorums(t *testing.T) {
+	network, nodes := newTestNetwork(3)
+	for _, opts := range [][]ClusterOption{
+		{WithQuorums(4, 2)},
+		{WithReplicas(3), WithQuorums(2, 4)},
+		{WithReplicas(0)},
+	} {
+		if _, err := NewCluster(nodes, network.Transport("c"), opts...); err == nil {
+			t.Errorf("NewCluster with invalid quorums succeeded")
+		}
+	}
+}
+
+func TestClusterNodeTombstoneSurvivesEviction(t *testing.T) {
+	clock := newFakeClock()
+	c := NewTypedCache[string, Versioned[int]](NewTypedMemoryStorage[string, Versioned[int]](1), NewTypedLRUEvictionPolicy[string](), WithClock(clock.Now))
+	n := NewClusterNode(c, WithTombstoneTTL(time.Minute))
+
+	n.Put("k", Versioned[int]{Value: 1, Version: 1})
+	n.Put("k", Versioned[int]{Version: 3, Deleted: true})
+	// Fill the one-entry cache, which would have evicted a cached tombstone.
+	n.Put("other", Versioned[int]{Value: 1, Version: 1})
+
+	// A lagging replica's write from before the delete can't undo it.
+	n.Put("k", Versioned[int]{Value: 2, Version: 2})
+	if got := n.Get("k"); !got.Deleted || got.Version != 3 {
+		t.Fatalf("Get after stale write: got %+v, want the tombstone at version 3", got)
+	}
+
+	// Once the tombstone expires the node no longer knows about the delete.
+	clock.Advance(time.Minute)
+	n.Put("k", Versioned[int]{Value: 2, Version: 2})
+	if got := n.Get("k"); got.Deleted || got.Value != 2 {
+		t.Errorf("Get after the tombstone expired: got %+v, want the stale write", got)
+	}
+	if len(n.tombstones) != 0 {
+		t.Errorf("%d tombstones left after expiry, want 0", len(n.tombstones))
+	}
+}
@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CoherentCacheConfig is needed to create a new coherent cache.
+type CoherentCacheConfig struct {
+	// TombstoneCapacity is the number of invalidated keys whose version is
+	// remembered, to reject writes of data older than the invalidation.
+	// Default is 1024.
+	TombstoneCapacity int
+	// Clock versions writes made without an explicit version.
+	// Default is the wrapped cache's clock.
+	Clock func() time.Time
+}
+
+// CoherentCacheOption is used to configure a new coherent cache.
+type CoherentCacheOption func(*CoherentCacheConfig)
+
+// WithTombstoneCapacity sets the number of invalidated keys whose version is
+// remembered.
+func WithTombstoneCapacity(n int) CoherentCacheOption {
+	return func(c *CoherentCacheConfig) {
+		c.TombstoneCapacity = n
+	}
+}
+
+// WithVersionClock sets the clock that versions writes made without an
+// explicit version.
+func WithVersionClock(clock func() time.Time) CoherentCacheOption {
+	return func(c *CoherentCacheConfig) {
+		c.Clock = clock
+	}
+}
+
+// ErrStaleVersion is returned for a write older than what the cache has
+// already seen for the key.
+var ErrStaleVersion = errors.New("stale version")
+
+// TypedCoherentCache is a process-local cache that stays coherent with its
+// peers in other instances. Every write is published on an invalidation bus,
+// and peers drop their copy of the key when they hear of it.
+//
+// Entries carry a version, and an invalidation only drops copies older than
+// itself, so a message that arrives late can't drop newer data. Invalidated
+// keys are remembered as tombstones for a while, so a slow writer can't put
+// back data older than the invalidation either. Versions should come from the
+// source of truth, such as a row's version, with PutVersioned; those made up
+// by Put from the clock are only as good as the clocks are in sync.
+//
+// TypedCoherentCache is safe for concurrent use.
+type TypedCoherentCache[K comparable, V any] struct {
+	id     string
+	cache  *TypedCache[K, Versioned[V]]
+	bus    InvalidationBus[K]
+	config CoherentCacheConfig
+
+	mu         sync.Mutex // Makes version checks and the writes after them atomic.
+	tombstones *TypedCache[K, uint64]
+	versions   *versionClock
+	cancel     func()
+}
+
+// NewCoherentCache creates a coherent cache storing entries in cache and
+// subscribed to bus. id must be unique among the instances on the bus.
+func NewCoherentCache[K comparable, V any](id string, cache *TypedCache[K, Versioned[V]], bus InvalidationBus[K], opts ...CoherentCacheOption) *TypedCoherentCache[K, V] {
+	const defaultTombstoneCapacity = 1024
+	c := CoherentCacheConfig{
+		TombstoneCapacity: defaultTombstoneCapacity,
+		Clock:             cache.config.Clock,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	cc := &TypedCoherentCache[K, V]{
+		id:     id,
+		cache:  cache,
+		bus:    bus,
+		config: c,
+		tombstones: NewTypedCache[K, uint64](
+			NewTypedMemoryStorage[K, uint64](c.TombstoneCapacity),
+			NewTypedLRUEvictionPolicy[K](),
+		),
+		versions: newVersionClock(c.Clock, stableHash(id)),
+	}
+	cc.cancel = bus.Subscribe(cc.receive)
+	return cc
+}
+
+func (c *TypedCoherentCache[K, V]) Get(key K) (V, error) {
+	v, err := c.cache.Get(key)
+	return v.Value, err
+}
+
+// Put stores value with a version taken from the clock and invalidates the
+// key in peers.
+func (c *TypedCoherentCache[K, V]) Put(key K, value V) error {
+	return c.PutVersioned(key, value, c.versions.next())
+}
+
+// PutVersioned stores value as the given version of key and invalidates the
+// key in peers. It returns ErrStaleVersion, without storing or publishing
+// anything, if the cache holds or has been told of a newer version.
+func (c *TypedCoherentCache[K, V]) PutVersioned(key K, value V, version uint64) error {
+	c.mu.Lock()
+	if latest, ok := c.latestLocked(key); ok && latest > version {
+		c.mu.Unlock()
+		return fmt.Errorf("putting key %v at version %d: %w", key, version, ErrStaleVersion)
+	}
+	err := c.cache.Put(key, Versioned[V]{Value: value, Version: version})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return c.publish(key, version)
+}
+
+// Delete drops key here and in peers, versioned from the clock.
+func (c *TypedCoherentCache[K, V]) Delete(key K) error {
+	return c.DeleteVersioned(key, c.versions.next())
+}
+
+// DeleteVersioned drops key, unless the cache holds a newer version of it,
+// and invalidates it in peers.
+func (c *TypedCoherentCache[K, V]) DeleteVersioned(key K, version uint64) error {
+	c.invalidate(key, version)
+	return c.publish(key, version)
+}
+
+func (c *TypedCoherentCache[K, V]) publish(key K, version uint64) error {
+	if err := c.bus.Publish(Invalidation[K]{Key: key, Version: version, Source: c.id}); err != nil {
+		return fmt.Errorf("publishing invalidation of key %v: %w", key, err)
+	}
+	return nil
+}
+
+// receive handles a message from the bus.
+func (c *TypedCoherentCache[K, V]) receive(msg Invalidation[K]) {
+	if msg.Source != c.id {
+		c.versions.observe(msg.Version)
+		c.invalidate(msg.Key, msg.Version)
+	}
+}
+
+// invalidate drops key if the cached copy is older than version, and
+// remembers version to reject older writes.
+func (c *TypedCoherentCache[K, V]) invalidate(key K, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cur, ok := c.cache.peek(key); ok && cur.Version >= version {
+		return
+	}
+	c.cache.Delete(key)
+	if tomb, ok := c.tombstones.peek(key); !ok || tomb < version {
+		c.tombstones.Put(key, version)
+	}
+}
+
+// latestLocked returns the newest version of key the cache knows of, from its
+// entry or tombstone. Looking them up doesn't count as a read of the key.
+func (c *TypedCoherentCache[K, V]) latestLocked(key K) (uint64, bool) {
+	var latest uint64
+	var ok bool
+	if cur, found := c.cache.peek(key); found {
+		latest, ok = cur.Version, true
+	}
+	if tomb, found := c.tombstones.peek(key); found && tomb > latest {
+		latest, ok = tomb, true
+	}
+	return latest, ok
+}
+
+// Cache returns the underlying cache.
+func (c *TypedCoherentCache[K, V]) Cache() *TypedCache[K, Versioned[V]] {
+	return c.cache
+}
+
+// Close unsubscribes from the bus and closes the underlying cache.
+func (c *TypedCoherentCache[K, V]) Close() error {
+	c.cancel()
+	c.tombstones.Close()
+	return c.cache.Close()
+}
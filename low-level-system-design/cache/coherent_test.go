@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestCoherentCache(id string, bus InvalidationBus[string], opts ...CoherentCacheOption) *TypedCoherentCache[string, string] {
+	c := NewTypedCache[string, Versioned[string]](NewTypedMemoryStorage[string, Versioned[string]](100), NewTypedLRUEvictionPolicy[string]())
+	return NewCoherentCache(id, c, bus, opts...)
+}
+
+// heldBus is an InvalidationBus that holds published messages until the test
+// delivers them, in any order.
+type heldBus struct {
+	handlers []func(Invalidation[string])
+	held     []Invalidation[string]
+}
+
+func (b *heldBus) Publish(msg Invalidation[string]) error {
+	b.held = append(b.held, msg)
+	return nil
+}
+
+func (b *heldBus) Subscribe(handler func(Invalidation[string])) func() {
+	b.handlers = append(b.handlers, handler)
+	return func() {}
+}
+
+func (b *heldBus) deliver(i int) {
+	for _, h := range b.handlers {
+		h(b.held[i])
+	}
+}
+
+func TestCoherentCacheInvalidatesPeers(t *testing.T) {
+	broker := NewMemoryBroker[string]()
+	a := newTestCoherentCache("a", broker)
+	b := newTestCoherentCache("b", broker)
+
+	if err := a.PutVersioned("k", "v1", 1); err != nil {
+		t.Fatalf("a.PutVersioned failed: %v", err)
+	}
+	if err := b.PutVersioned("k", "v1", 1); err != nil {
+		t.Fatalf("b.PutVersioned of the same version failed: %v", err)
+	}
+
+	// A newer write in a drops b's copy but not a's own.
+	a.PutVersioned("k", "v2", 2)
+	if got, err := a.Get("k"); err != nil || got != "v2" {
+		t.Errorf("a.Get: got %q, %v, want v2, nil", got, err)
+	}
+	if _, err := b.Get("k"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("b.Get after a's write: got %v, want ErrKeyNotFound", err)
+	}
+
+	b.PutVersioned("k", "v2", 2)
+	b.Delete("k")
+	for name, c := range map[string]*TypedCoherentCache[string, string]{"a": a, "b": b} {
+		if _, err := c.Get("k"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("%s.Get after Delete: got %v, want ErrKeyNotFound", name, err)
+		}
+	}
+}
+
+func TestCoherentCacheReorderedInvalidations(t *testing.T) {
+	bus := &heldBus{}
+	a := newTestCoherentCache("a", bus)
+	b := newTestCoherentCache("b", bus)
+	b.PutVersioned("k", "v1", 1)
+
+	a.PutVersioned("k", "v2", 2)
+	a.PutVersioned("k", "v3", 3)
+	bus.deliver(2) // v3 overtakes v2.
+	bus.deliver(1)
+
+	if _, err := b.Get("k"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("b.Get after invalidation: got %v, want ErrKeyNotFound", err)
+	}
+	// A slow writer can't put back data the invalidation superseded.
+	if err := b.PutVersioned("k", "v2", 2); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("b.PutVersioned(v2): got %v, want ErrStaleVersion", err)
+	}
+	if err := b.PutVersioned("k", "v3", 3); err != nil {
+		t.Errorf("b.PutVersioned(v3): %v", err)
+	}
+
+	// Replaying the late message doesn't drop the newer copy.
+	bus.deliver(1)
+	if got, err := b.Get("k"); err != nil || got != "v3" {
+		t.Errorf("b.Get after a late message: got %q, %v, want v3, nil", got, err)
+	}
+	if n := len(bus.held); n != 4 {
+		t.Errorf("published %d messages, want 4", n)
+	}
+}
+
+func TestCoherentCacheStalePut(t *testing.T) {
+	c := newTestCoherentCache("a", NewMemoryBroker[string]())
+	c.PutVersioned("k", "v5", 5)
+	if err := c.PutVersioned("k", "v4", 4); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("PutVersioned of an older version: got %v, want ErrStaleVersion", err)
+	}
+	if got, _ := c.Get("k"); got != "v5" {
+		t.Errorf("Get: got %q, want v5", got)
+	}
+
+	// Versions from the clock always increase.
+	clock := newFakeClock()
+	c = newTestCoherentCache("a", NewMemoryBroker[string](), WithVersionClock(clock.Now))
+	c.Put("k", "first")
+	if err := c.Put("k", "second"); err != nil {
+		t.Errorf("second Put at the same instant: %v", err)
+	}
+}
+
+func TestCoherentCacheVersionChecksArentReads(t *testing.T) {
+	bus := &heldBus{}
+	c := NewCoherentCache("a", NewTypedCache[string, Versioned[string]](NewTypedMemoryStorage[string, Versioned[string]](2), NewTypedLRUEvictionPolicy[string]()), bus)
+	c.PutVersioned("a", "v1", 1)
+	c.PutVersioned("b", "v1", 1)
+
+	// Checking a's version against a late invalidation doesn't make it
+	// recently used, so c evicts it.
+	c.receive(Invalidation[string]{Key: "a", Version: 1, Source: "b"})
+	c.PutVersioned("c", "v1", 1)
+	if _, err := c.Cache().Get("a"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get(a): got %v, want a evicted", err)
+	}
+
+	stats := c.Cache().Stats()
+	if stats.Hits != 0 || stats.Misses != 1 {
+		t.Errorf("Stats: got %d hits, %d misses, want only the test's miss", stats.Hits, stats.Misses)
+	}
+	if stats := c.tombstones.Stats(); stats.Hits+stats.Misses != 0 {
+		t.Errorf("tombstone Stats: got %d hits, %d misses, want none", stats.Hits, stats.Misses)
+	}
+}
+
+func TestCoherentCacheClose(t *testing.T) {
+	broker := NewMemoryBroker[string]()
+	a := newTestCoherentCache("a", broker)
+	b := newTestCoherentCache("b", broker)
+	b.PutVersioned("k", "v1", 1)
+	b.Close()
+
+	a.PutVersioned("k", "v2", 2)
+	if got, err := b.Cache().Get("k"); err != nil || got.Value != "v1" {
+		t.Errorf("closed cache got the invalidation: got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,365 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+)
+
+// DiskStorage holds arbitrary keys and values.
+type DiskStorage = TypedDiskStorage[any, any]
+
+// TypedDiskStorage holds entries in an append-only log file with an in-memory
+// index of where each key's latest record is. Adds and removes append a record;
+// reads seek to it. Records that have been overwritten or removed are dropped
+// by compaction, which rewrites the log once enough of it is stale.
+//
+// Opening a log replays it to rebuild the index. A record cut short or
+// corrupted by a crash ends the replay and is truncated away, so at most the
+// writes in flight at the time of the crash are lost.
+//
+// Like MemoryStorage it is not safe for concurrent use on its own. Get returns
+// false if the record can't be read back.
+type TypedDiskStorage[K comparable, V any] struct {
+	path   string
+	f      *os.File
+	index  map[K]diskEntry
+	cap    int
+	end    int64 // Offset of the next record.
+	stale  int64 // Bytes taken by records no longer in the index.
+	config DiskStorageConfig
+}
+
+// diskEntry locates a record in the log.
+type diskEntry struct {
+	offset int64
+	length int64 // Including the header.
+}
+
+// diskRecord is the payload of a record.
+type diskRecord[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// A record is a header followed by the encoded diskRecord. The checksum
+// covers the op and the payload.
+//
+//	crc32 (4 bytes) | payload length (4 bytes) | op (1 byte) | payload
+const diskHeaderSize = 9
+
+const (
+	opPut byte = iota + 1
+	opRemove
+)
+
+// minCompactSize is the smallest log worth compacting.
+const minCompactSize = 64 << 10
+
+// DiskStorageConfig is needed to create a new disk storage.
+type DiskStorageConfig struct {
+	// Codec encodes keys and values.
+	// Default is GobCodec.
+	Codec Codec
+	// SyncWrites makes every add and remove wait for the log to reach the
+	// disk, so not even the last write is lost in a crash.
+	// Default is false.
+	SyncWrites bool
+	// CompactRatio is the share of the log that must be stale before it is
+	// compacted. Logs under 64 KiB are never compacted automatically.
+	// Default is 0.5.
+	CompactRatio float64
+}
+
+// DiskStorageOption is used to configure a new disk storage.
+type DiskStorageOption func(*DiskStorageConfig)
+
+// WithCodec sets how keys and values are encoded.
+func WithCodec(codec Codec) DiskStorageOption {
+	return func(c *DiskStorageConfig) {
+		c.Codec = codec
+	}
+}
+
+// WithSyncWrites syncs the log to disk after every write.
+func WithSyncWrites() DiskStorageOption {
+	return func(c *DiskStorageConfig) {
+		c.SyncWrites = true
+	}
+}
+
+// WithCompactRatio sets the share of the log that must be stale before it is compacted.
+func WithCompactRatio(ratio float64) DiskStorageOption {
+	return func(c *DiskStorageConfig) {
+		c.CompactRatio = ratio
+	}
+}
+
+func OpenDiskStorage(path string, cap int, opts ...DiskStorageOption) (*DiskStorage, error) {
+	return OpenTypedDiskStorage[any, any](path, cap, opts...)
+}
+
+// OpenTypedDiskStorage opens the log at path, creating it if needed, and
+// recovers the entries in it. The storage holds up to cap keys.
+func OpenTypedDiskStorage[K comparable, V any](path string, cap int, opts ...DiskStorageOption) (*TypedDiskStorage[K, V], error) {
+	const defaultCompactRatio = 0.5
+	c := DiskStorageConfig{Codec: GobCodec{}, CompactRatio: defaultCompactRatio}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	d := &TypedDiskStorage[K, V]{
+		path:   path,
+		f:      f,
+		index:  make(map[K]diskEntry),
+		cap:    cap,
+		config: c,
+	}
+	if err := d.recover(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("recovering %s: %w", path, err)
+	}
+	return d, nil
+}
+
+// recover replays the log into the index and truncates anything after the
+// last intact record.
+func (d *TypedDiskStorage[K, V]) recover() error {
+	info, err := d.f.Stat()
+	if err != nil {
+		return err
+	}
+	d.end = info.Size()
+
+	var offset int64
+	for {
+		op, payload, length, err := d.readRecord(offset)
+		if err != nil {
+			break
+		}
+		var rec diskRecord[K, V]
+		if err := d.config.Codec.Unmarshal(payload, &rec); err != nil {
+			break
+		}
+
+		d.stale += d.index[rec.Key].length
+		if op == opPut {
+			d.index[rec.Key] = diskEntry{offset: offset, length: length}
+		} else {
+			delete(d.index, rec.Key)
+			d.stale += length
+		}
+		offset += length
+	}
+
+	d.end = offset
+	return d.f.Truncate(offset)
+}
+
+// readRecord reads the record at offset and checks its checksum.
+func (d *TypedDiskStorage[K, V]) readRecord(offset int64) (op byte, payload []byte, length int64, err error) {
+	var header [diskHeaderSize]byte
+	if _, err := d.f.ReadAt(header[:], offset); err != nil {
+		return 0, nil, 0, err
+	}
+	sum := binary.LittleEndian.Uint32(header[0:4])
+	n := binary.LittleEndian.Uint32(header[4:8])
+	op = header[8]
+	// A torn header can claim any length, so check it against the log.
+	if offset+diskHeaderSize+int64(n) > d.end {
+		return 0, nil, 0, errCorruptRecord
+	}
+
+	payload = make([]byte, n)
+	if _, err := d.f.ReadAt(payload, offset+diskHeaderSize); err != nil {
+		return 0, nil, 0, err
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(header[8:9])
+	crc.Write(payload)
+	if crc.Sum32() != sum || (op != opPut && op != opRemove) {
+		return 0, nil, 0, errCorruptRecord
+	}
+	return op, payload, diskHeaderSize + int64(n), nil
+}
+
+var errCorruptRecord = errors.New("corrupt record")
+
+// appendRecord writes a record at the end of the log and returns where it is.
+func (d *TypedDiskStorage[K, V]) appendRecord(op byte, key K, value V) (diskEntry, error) {
+	payload, err := d.config.Codec.Marshal(diskRecord[K, V]{Key: key, Value: value})
+	if err != nil {
+		return diskEntry{}, fmt.Errorf("encoding key %v: %w", key, err)
+	}
+
+	buf := make([]byte, diskHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(len(payload)))
+	buf[8] = op
+	copy(buf[diskHeaderSize:], payload)
+	binary.LittleEndian.PutUint32(buf[0:4], crc32.ChecksumIEEE(buf[8:]))
+
+	if _, err := d.f.WriteAt(buf, d.end); err != nil {
+		return diskEntry{}, err
+	}
+	if d.config.SyncWrites {
+		if err := d.f.Sync(); err != nil {
+			return diskEntry{}, err
+		}
+	}
+
+	e := diskEntry{offset: d.end, length: int64(len(buf))}
+	d.end += e.length
+	return e, nil
+}
+
+func (d *TypedDiskStorage[K, V]) Add(key K, value V) error {
+	old, exists := d.index[key]
+	if !exists && len(d.index) >= d.cap {
+		return ErrStorageFull
+	}
+
+	e, err := d.appendRecord(opPut, key, value)
+	if err != nil {
+		return err
+	}
+	d.index[key] = e
+	d.stale += old.length
+	d.maybeCompact()
+	return nil
+}
+
+// Remove appends a tombstone for key. If that fails the key is still dropped
+// from the index, but may come back when the log is next opened.
+func (d *TypedDiskStorage[K, V]) Remove(key K) {
+	old, ok := d.index[key]
+	if !ok {
+		return
+	}
+
+	delete(d.index, key)
+	d.stale += old.length
+	var zero V
+	if e, err := d.appendRecord(opRemove, key, zero); err == nil {
+		d.stale += e.length
+	}
+	d.maybeCompact()
+}
+
+func (d *TypedDiskStorage[K, V]) Get(key K) (V, bool) {
+	var rec diskRecord[K, V]
+	e, ok := d.index[key]
+	if !ok {
+		return rec.Value, false
+	}
+
+	_, payload, _, err := d.readRecord(e.offset)
+	if err != nil {
+		return rec.Value, false
+	}
+	if err := d.config.Codec.Unmarshal(payload, &rec); err != nil {
+		return rec.Value, false
+	}
+	return rec.Value, true
+}
+
+func (d *TypedDiskStorage[K, V]) Len() int {
+	return len(d.index)
+}
+
+func (d *TypedDiskStorage[K, V]) Keys() []K {
+	keys := make([]K, 0, len(d.index))
+	for key := range d.index {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Clear removes every key and empties the log. If the log can't be truncated,
+// the keys are kept so the index still matches it.
+func (d *TypedDiskStorage[K, V]) Clear() error {
+	if err := d.f.Truncate(0); err != nil {
+		return fmt.Errorf("clearing %s: %w", d.path, err)
+	}
+	clear(d.index)
+	d.end, d.stale = 0, 0
+	return nil
+}
+
+// Size returns the size of the log in bytes.
+func (d *TypedDiskStorage[K, V]) Size() int64 {
+	return d.end
+}
+
+// maybeCompact compacts the log if enough of it is stale. A failed compaction
+// leaves the old log in place and is tried again after the next write.
+func (d *TypedDiskStorage[K, V]) maybeCompact() {
+	if d.end < minCompactSize || float64(d.stale) < d.config.CompactRatio*float64(d.end) {
+		return
+	}
+	d.Compact()
+}
+
+// Compact rewrites the log with only the latest record for each key. The new
+// log is written to a temporary file and synced before it replaces the old
+// one, so a crash during compaction leaves the old log intact.
+func (d *TypedDiskStorage[K, V]) Compact() error {
+	tmp := d.path + ".compact"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[K]diskEntry, len(d.index))
+	var end int64
+	for key, e := range d.index {
+		buf := make([]byte, e.length)
+		if _, err = d.f.ReadAt(buf, e.offset); err != nil {
+			break
+		}
+		if _, err = f.WriteAt(buf, end); err != nil {
+			break
+		}
+		index[key] = diskEntry{offset: end, length: e.length}
+		end += e.length
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, d.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("compacting %s: %w", d.path, err)
+	}
+
+	// Sync the directory so the rename itself survives a crash.
+	if dir, err := os.Open(filepath.Dir(d.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	d.f.Close()
+	d.f = f
+	d.index = index
+	d.end = end
+	d.stale = 0
+	return nil
+}
+
+// Close syncs and closes the log.
+func (d *TypedDiskStorage[K, V]) Close() error {
+	err := d.f.Sync()
+	if closeErr := d.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
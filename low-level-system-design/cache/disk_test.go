@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func openTestDiskStorage(t *testing.T, path string, cap int, opts ...DiskStorageOption) *TypedDiskStorage[string, []byte] {
+	t.Helper()
+	d, err := OpenTypedDiskStorage[string, []byte](path, cap, opts...)
+	if err != nil {
+		t.Fatalf("OpenTypedDiskStorage failed: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestDiskStorageAddGetRemove(t *testing.T) {
+	d := openTestDiskStorage(t, filepath.Join(t.TempDir(), "log"), 2)
+
+	d.Add("a", []byte("1"))
+	d.Add("b", []byte("2"))
+	if err := d.Add("c", []byte("3")); !errors.Is(err, ErrStorageFull) {
+		t.Fatalf("Add(c): got %v, want ErrStorageFull", err)
+	}
+	if err := d.Add("a", []byte("11")); err != nil {
+		t.Fatalf("overwriting a: %v", err)
+	}
+	if got, ok := d.Get("a"); !ok || string(got) != "11" {
+		t.Errorf("Get(a): got %q, %v, want 11, true", got, ok)
+	}
+
+	d.Remove("b")
+	if _, ok := d.Get("b"); ok {
+		t.Error("Get(b) found a removed key")
+	}
+	if err := d.Add("c", []byte("3")); err != nil {
+		t.Errorf("Add(c) after Remove: %v", err)
+	}
+
+	keys := d.Keys()
+	slices.Sort(keys)
+	if want := []string{"a", "c"}; !slices.Equal(keys, want) {
+		t.Errorf("Keys: got %v, want %v", keys, want)
+	}
+}
+
+func TestDiskStorageRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	d := openTestDiskStorage(t, path, 10)
+	d.Add("a", []byte("1"))
+	d.Add("b", []byte("2"))
+	d.Add("a", []byte("3"))
+	d.Remove("b")
+	d.Add("c", []byte("4"))
+	d.Close()
+
+	testCases := []struct {
+		name string
+		tail []byte // Appended to the log to simulate a crash mid-write.
+	}{
+		{"clean", nil},
+		{"torn header", []byte{1, 2, 3}},
+		{"torn payload", []byte{0, 0, 0, 0, 100, 0, 0, 0, opPut, 'x'}},
+		{"bad checksum", []byte{0, 0, 0, 0, 1, 0, 0, 0, opPut, 'x'}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			crashed := filepath.Join(t.TempDir(), "log")
+			os.WriteFile(crashed, append(data, tc.tail...), 0o644)
+
+			d := openTestDiskStorage(t, crashed, 10)
+			if d.Len() != 2 {
+				t.Errorf("Len: got %d, want 2", d.Len())
+			}
+			for key, want := range map[string]string{"a": "3", "c": "4"} {
+				if got, ok := d.Get(key); !ok || string(got) != want {
+					t.Errorf("Get(%q): got %q, %v, want %q, true", key, got, ok, want)
+				}
+			}
+			if _, ok := d.Get("b"); ok {
+				t.Error("Get(b) found a removed key")
+			}
+			if d.Size() != int64(len(data)) {
+				t.Errorf("Size: got %d, want the corrupt tail truncated to %d", d.Size(), len(data))
+			}
+
+			// New writes land after the last good record.
+			d.Add("d", []byte("5"))
+			d.Close()
+			d = openTestDiskStorage(t, crashed, 10)
+			if got, ok := d.Get("d"); !ok || string(got) != "5" {
+				t.Errorf("Get(d) after reopening: got %q, %v, want 5, true", got, ok)
+			}
+		})
+	}
+}
+
+func TestDiskStorageCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	d := openTestDiskStorage(t, path, 10)
+	for i := 0; i < 100; i++ {
+		d.Add("a", bytes.Repeat([]byte{byte(i)}, 100))
+	}
+	d.Add("b", []byte("b"))
+	d.Add("c", []byte("c"))
+	d.Remove("c")
+
+	before := d.Size()
+	if err := d.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if d.Size() >= before/10 {
+		t.Errorf("Size after Compact: got %d, want well under %d", d.Size(), before)
+	}
+	if got, ok := d.Get("a"); !ok || got[0] != 99 {
+		t.Errorf("Get(a) after Compact: got %.1v, %v, want the last write", got, ok)
+	}
+
+	// The compacted log must survive a reopen.
+	d.Close()
+	d = openTestDiskStorage(t, path, 10)
+	keys := d.Keys()
+	slices.Sort(keys)
+	if want := []string{"a", "b"}; !slices.Equal(keys, want) {
+		t.Errorf("Keys after reopening: got %v, want %v", keys, want)
+	}
+}
+
+func TestDiskStorageAutoCompact(t *testing.T) {
+	d := openTestDiskStorage(t, filepath.Join(t.TempDir(), "log"), 10)
+	value := make([]byte, 1024)
+	for i := 0; i < 500; i++ {
+		d.Add("a", value)
+	}
+
+	// Without compaction the log would be over 500 KiB.
+	if d.Size() > 4*minCompactSize {
+		t.Errorf("Size: got %d, want the log compacted", d.Size())
+	}
+	if _, ok := d.Get("a"); !ok {
+		t.Error("Get(a) failed after compaction")
+	}
+}
+
+func TestCacheOverDiskStorageTracksRecoveredKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	d := openTestDiskStorage(t, path, 2)
+	d.Add("a", []byte("1"))
+	d.Add("b", []byte("2"))
+	d.Close()
+
+	d = openTestDiskStorage(t, path, 2)
+	c := NewTypedCache[string, []byte](d, NewTypedLRUEvictionPolicy[string]())
+	if err := c.Put("c", []byte("3")); err != nil {
+		t.Fatalf("Put into a full recovered storage: %v", err)
+	}
+	if got, err := c.Get("c"); err != nil || string(got) != "3" {
+		t.Errorf("Get(c): got %q, %v, want 3, nil", got, err)
+	}
+}
+
+func TestDiskStorageClear(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	d := openTestDiskStorage(t, path, 10)
+	d.Add("a", []byte("1"))
+	d.Add("b", []byte("2"))
+	if err := d.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if d.Len() != 0 || d.Size() != 0 {
+		t.Errorf("after Clear: got %d keys in %d bytes, want none", d.Len(), d.Size())
+	}
+	d.Close()
+	if n := openTestDiskStorage(t, path, 10).Len(); n != 0 {
+		t.Errorf("Len after reopening: got %d, want 0", n)
+	}
+
+	// A log that can't be truncated keeps its keys.
+	d = openTestDiskStorage(t, path, 10)
+	d.Add("a", []byte("1"))
+	d.f.Close()
+	if err := d.Clear(); err == nil {
+		t.Fatal("Clear of a closed log succeeded")
+	}
+	if n := d.Len(); n != 1 {
+		t.Errorf("Len after a failed Clear: got %d, want 1", n)
+	}
+}
@@ -0,0 +1,139 @@
+package cache
+
+import "fmt"
+
+// EvictionReason says why a key left the cache.
+type EvictionReason int
+
+const (
+	// EvictionCapacity means the key was evicted to make room for another.
+	EvictionCapacity EvictionReason = iota + 1
+	// EvictionExpired means the key's TTL ran out.
+	EvictionExpired
+	// EvictionDeleted means the key was removed with Delete.
+	EvictionDeleted
+	// EvictionReplaced means the key's value was overwritten by Put. The
+	// key is still cached; the old value is the one reported.
+	EvictionReplaced
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionCapacity:
+		return "capacity"
+	case EvictionExpired:
+		return "expired"
+	case EvictionDeleted:
+		return "deleted"
+	case EvictionReplaced:
+		return "replaced"
+	default:
+		return fmt.Sprintf("EvictionReason(%d)", int(r))
+	}
+}
+
+// evictionEvent is a key that left the cache, waiting to be reported.
+type evictionEvent[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictionReason
+}
+
+// OnEvict sets fn to be called with every key and value that leaves the cache,
+// replacing any earlier hook. fn is called after the cache's lock is released,
+// so it may use the cache. By default it runs on the goroutine whose call
+// removed the key, before that call returns; with WithAsyncEvictions it runs
+// on a background goroutine instead, until the cache is closed.
+func (c *TypedCache[K, V]) OnEvict(fn func(key K, value V, reason EvictionReason)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = fn
+}
+
+// recordLocked counts an eviction and queues it to be reported once the lock
+// is released.
+func (c *TypedCache[K, V]) recordLocked(key K, value V, reason EvictionReason) {
+	c.stats.evictions[reason].Add(1)
+	if c.onEvict == nil {
+		return
+	}
+	c.events = append(c.events, evictionEvent[K, V]{key: key, value: value, reason: reason})
+}
+
+// unlock releases the lock and then reports the evictions made while holding it.
+func (c *TypedCache[K, V]) unlock() {
+	events, fn := c.events, c.onEvict
+	c.events = nil
+	if len(events) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	if c.asyncReady == nil {
+		c.mu.Unlock()
+		report(events, fn)
+		return
+	}
+
+	// Hand over from mu to asyncMu so a later call can't queue its evictions
+	// ahead of these. asyncMu is never held while waiting on the hook, so the
+	// hook can evict keys itself.
+	c.asyncMu.Lock()
+	c.mu.Unlock()
+	if c.asyncDone {
+		// The cache is closed and every queued eviction has been reported.
+		c.asyncMu.Unlock()
+		report(events, fn)
+		return
+	}
+	for _, e := range events {
+		c.asyncEvents = append(c.asyncEvents, asyncEvictionEvent[K, V]{evictionEvent: e, fn: fn})
+	}
+	c.asyncMu.Unlock()
+	select {
+	case c.asyncReady <- struct{}{}:
+	default: // Already signalled.
+	}
+}
+
+func report[K comparable, V any](events []evictionEvent[K, V], fn func(key K, value V, reason EvictionReason)) {
+	for _, e := range events {
+		fn(e.key, e.value, e.reason)
+	}
+}
+
+// asyncEvictionEvent carries the hook that was set when the eviction happened.
+type asyncEvictionEvent[K comparable, V any] struct {
+	evictionEvent[K, V]
+	fn func(key K, value V, reason EvictionReason)
+}
+
+// deliverEvictions calls the hook for queued evictions in order until the
+// cache is closed and the queue is empty. Later evictions are reported
+// synchronously.
+func (c *TypedCache[K, V]) deliverEvictions() {
+	defer close(c.evictionsDone)
+	closed := false
+	for {
+		if !closed {
+			select {
+			case <-c.asyncReady:
+			case <-c.stop:
+				closed = true
+			}
+		}
+
+		c.asyncMu.Lock()
+		events := c.asyncEvents
+		c.asyncEvents = nil
+		if closed && len(events) == 0 {
+			c.asyncDone = true
+			c.asyncMu.Unlock()
+			return
+		}
+		c.asyncMu.Unlock()
+
+		for _, e := range events {
+			e.fn(e.key, e.value, e.reason)
+		}
+	}
+}
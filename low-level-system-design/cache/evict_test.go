@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type evicted struct {
+	key    string
+	value  int
+	reason EvictionReason
+}
+
+// recordEvictions sets a hook on c that appends to the returned slice.
+func recordEvictions(c *TypedCache[string, int]) func() []evicted {
+	var mu sync.Mutex
+	var got []evicted
+	c.OnEvict(func(key string, value int, reason EvictionReason) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, evicted{key, value, reason})
+	})
+	return func() []evicted {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]evicted(nil), got...)
+	}
+}
+
+func TestCacheOnEvictReasons(t *testing.T) {
+	clock := newFakeClock()
+	c := newTTLCache(2, clock)
+	got := recordEvictions(c)
+
+	c.Put("a", 1)
+	c.Put("a", 2) // Replaced.
+	c.Put("b", 1)
+	c.Put("c", 1) // Evicts a for capacity.
+	c.Delete("b") // Deleted.
+	c.PutWithTTL("d", 1, time.Minute)
+	clock.Advance(time.Minute)
+	c.Get("d") // Expired on read.
+	c.PutWithTTL("e", 1, time.Minute)
+	clock.Advance(time.Minute)
+	c.Put("f", 1) // Expired to make room.
+	c.Delete("missing")
+
+	want := []evicted{
+		{"a", 1, EvictionReplaced},
+		{"a", 2, EvictionCapacity},
+		{"b", 1, EvictionDeleted},
+		{"d", 1, EvictionExpired},
+		{"e", 1, EvictionExpired},
+	}
+	if !reflect.DeepEqual(got(), want) {
+		t.Errorf("evictions:\ngot  %v\nwant %v", got(), want)
+	}
+}
+
+func TestCacheOnEvictCalledWithoutLock(t *testing.T) {
+	c := newTTLCache(1, newFakeClock())
+	var seen []int
+	c.OnEvict(func(key string, value int, reason EvictionReason) {
+		// Calling back into the cache would deadlock if the lock were held.
+		v, _ := c.Get("b")
+		seen = append(seen, v)
+	})
+
+	c.Put("a", 1)
+	done := make(chan struct{})
+	go func() {
+		c.Put("b", 2)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Put deadlocked calling the OnEvict hook")
+	}
+	if !reflect.DeepEqual(seen, []int{2}) {
+		t.Errorf("hook saw %v, want [2]", seen)
+	}
+}
+
+func TestCacheOnEvictAsync(t *testing.T) {
+	c := newTTLCache(1, newFakeClock(), WithAsyncEvictions(4))
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var got []string
+	c.OnEvict(func(key string, value int, reason EvictionReason) {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, key)
+	})
+
+	// Puts return while the hook is blocked.
+	keys := []string{"a", "b", "c", "d"}
+	for _, key := range keys {
+		c.Put(key, 1)
+	}
+	close(release)
+
+	// Close waits for queued evictions to be reported, in order.
+	c.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if want := keys[:3]; !reflect.DeepEqual(got, want) {
+		t.Errorf("evictions: got %v, want %v", got, want)
+	}
+}
+
+func TestCacheOnEvictAsyncAfterClose(t *testing.T) {
+	c := newTTLCache(1, newFakeClock(), WithAsyncEvictions(4))
+	got := recordEvictions(c)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Close()
+
+	// Put reports its eviction before returning, as there is no goroutine
+	// left to do it.
+	c.Put("c", 3)
+	want := []evicted{{"a", 1, EvictionCapacity}, {"b", 2, EvictionCapacity}}
+	if !reflect.DeepEqual(got(), want) {
+		t.Errorf("evictions after Close: got %v, want %v", got(), want)
+	}
+}
+
+// insertOrderPolicy records the order keys are added in, which is the order
+// the cache's lock was taken by the Puts adding them.
+type insertOrderPolicy struct {
+	TypedEvictionPolicy[string]
+	added []string
+}
+
+func (p *insertOrderPolicy) ItemAccessed(key string) {
+	p.added = append(p.added, key)
+	p.TypedEvictionPolicy.ItemAccessed(key)
+}
+
+func TestCacheOnEvictAsyncConcurrentOrder(t *testing.T) {
+	const (
+		numWorkers = 8
+		numPuts    = 200
+	)
+	policy := &insertOrderPolicy{TypedEvictionPolicy: NewTypedLRUEvictionPolicy[string]()}
+	c := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](1), policy, WithAsyncEvictions(1))
+	got := recordEvictions(c)
+
+	var wg sync.WaitGroup
+	for w := range numWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range numPuts {
+				c.Put(fmt.Sprintf("%d-%d", w, i), i)
+			}
+		}()
+	}
+	wg.Wait()
+	c.Close()
+
+	// With room for one key, every Put evicts the key added just before it.
+	evictions := got()
+	if len(evictions) != numWorkers*numPuts-1 {
+		t.Fatalf("got %d evictions, want %d", len(evictions), numWorkers*numPuts-1)
+	}
+	for i, e := range evictions {
+		if want := policy.added[i]; e.key != want {
+			t.Fatalf("eviction %d: got %s, want %s", i, e.key, want)
+		}
+	}
+}
+
+func TestCacheOnEvictAsyncHookEvicts(t *testing.T) {
+	c := NewTypedCache[int, int](NewTypedMemoryStorage[int, int](2), NewTypedLRUEvictionPolicy[int](), WithAsyncEvictions(1))
+	var mu sync.Mutex
+	var hooked int
+	c.OnEvict(func(key, value int, reason EvictionReason) {
+		mu.Lock()
+		hooked++
+		mu.Unlock()
+		// Each of these Puts evicts too, queueing from the goroutine that
+		// delivers evictions.
+		if key < 1000 {
+			c.Put(1000+key, value)
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		for i := range 50 {
+			c.Put(i, i)
+		}
+		c.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Put deadlocked with a hook that evicts keys")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if hooked < 48 {
+		t.Errorf("hook called %d times, want at least 48", hooked)
+	}
+}
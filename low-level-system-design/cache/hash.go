@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"hash/maphash"
+)
+
+// keyBytes encodes a key for hashing.
+func keyBytes(key any) []byte {
+	switch k := key.(type) {
+	case string:
+		return []byte(k)
+	case int:
+		return binary.LittleEndian.AppendUint64(nil, uint64(k))
+	case int64:
+		return binary.LittleEndian.AppendUint64(nil, uint64(k))
+	case uint64:
+		return binary.LittleEndian.AppendUint64(nil, k)
+	case int32:
+		return binary.LittleEndian.AppendUint32(nil, uint32(k))
+	case uint32:
+		return binary.LittleEndian.AppendUint32(nil, k)
+	default:
+		return fmt.Appendf(nil, "%T:%v", key, key)
+	}
+}
+
+// keyHasher hashes keys without allocating for strings and integers.
+type keyHasher struct {
+	seed maphash.Seed
+	salt uint64
+}
+
+func newKeyHasher() keyHasher {
+	seed := maphash.MakeSeed()
+	return keyHasher{seed: seed, salt: maphash.String(seed, "salt")}
+}
+
+func hashKey[K comparable](h keyHasher, key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return maphash.String(h.seed, k)
+	case int:
+		return mix64(uint64(k) ^ h.salt)
+	case int64:
+		return mix64(uint64(k) ^ h.salt)
+	case uint64:
+		return mix64(k ^ h.salt)
+	default:
+		// Box the key separately so the conversion above doesn't escape.
+		return maphash.Bytes(h.seed, keyBytes(any(key)))
+	}
+}
+
+// stableHash hashes key the same way in every process, unlike hashKey, whose
+// seed is random.
+func stableHash(key any) uint64 {
+	h := fnv.New64a()
+	h.Write(keyBytes(key))
+	return mix64(h.Sum64())
+}
+
+// mix64 is the splitmix64 finalizer. It scrambles integer keys so consecutive
+// keys spread evenly.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,151 @@
+package cache
+
+// LFUEvictionPolicy tracks arbitrary keys.
+type LFUEvictionPolicy = TypedLFUEvictionPolicy[any]
+
+// TypedLFUEvictionPolicy evicts the least frequently used key, breaking ties by
+// evicting the least recently used one.
+//
+// Keys with the same access count share a bucket, and the buckets are kept in
+// a list ordered by count with the lowest at the tail. An access moves a key
+// to the neighbouring bucket, so both ItemAccessed and Evict are O(1).
+type TypedLFUEvictionPolicy[K comparable] struct {
+	buckets *TypedDoublyLinkedList[*lfuBucket[K]]
+	lut     map[K]*lfuEntry[K]
+}
+
+type lfuBucket[K comparable] struct {
+	freq int
+	keys TypedDoublyLinkedList[K] // Most recently used at the head.
+}
+
+type lfuEntry[K comparable] struct {
+	node   *TypedDoubleLinkNode[K]
+	bucket *TypedDoubleLinkNode[*lfuBucket[K]]
+}
+
+func NewLFUEvictionPolicy() *LFUEvictionPolicy {
+	return NewTypedLFUEvictionPolicy[any]()
+}
+
+func NewTypedLFUEvictionPolicy[K comparable]() *TypedLFUEvictionPolicy[K] {
+	return &TypedLFUEvictionPolicy[K]{
+		buckets: new(TypedDoublyLinkedList[*lfuBucket[K]]),
+		lut:     make(map[K]*lfuEntry[K]),
+	}
+}
+
+func (l *TypedLFUEvictionPolicy[K]) ItemAccessed(item K) {
+	entry, ok := l.lut[item]
+	if !ok {
+		bucket := l.buckets.tail
+		if bucket == nil || bucket.Val.freq != 1 {
+			bucket = &TypedDoubleLinkNode[*lfuBucket[K]]{Val: &lfuBucket[K]{freq: 1}}
+			l.buckets.AddToTail(bucket)
+		}
+		l.lut[item] = &lfuEntry[K]{
+			node:   bucket.Val.keys.AddItemToHead(item),
+			bucket: bucket,
+		}
+		return
+	}
+
+	cur := entry.bucket
+	next := cur.Prev
+	if next == nil || next.Val.freq != cur.Val.freq+1 {
+		next = &TypedDoubleLinkNode[*lfuBucket[K]]{Val: &lfuBucket[K]{freq: cur.Val.freq + 1}}
+		l.buckets.InsertBefore(next, cur)
+	}
+
+	cur.Val.keys.RemoveNode(entry.node)
+	next.Val.keys.AddToHead(entry.node)
+	entry.bucket = next
+	l.removeIfEmpty(cur)
+}
+
+// Victim returns the key Evict would remove without removing it.
+func (l *TypedLFUEvictionPolicy[K]) Victim() (K, bool) {
+	if l.buckets.tail == nil {
+		var zero K
+		return zero, false
+	}
+	return l.buckets.tail.Val.keys.tail.Val, true
+}
+
+// Evict removes the least frequently used key and returns it, or the zero value
+// if no keys are tracked.
+func (l *TypedLFUEvictionPolicy[K]) Evict() K {
+	bucket := l.buckets.tail
+	if bucket == nil {
+		var zero K
+		return zero
+	}
+
+	node := bucket.Val.keys.RemoveTail()
+	l.removeIfEmpty(bucket)
+	delete(l.lut, node.Val)
+	return node.Val
+}
+
+func (l *TypedLFUEvictionPolicy[K]) Remove(item K) {
+	entry, ok := l.lut[item]
+	if !ok {
+		return
+	}
+
+	entry.bucket.Val.keys.RemoveNode(entry.node)
+	l.removeIfEmpty(entry.bucket)
+	delete(l.lut, item)
+}
+
+func (l *TypedLFUEvictionPolicy[K]) removeIfEmpty(bucket *TypedDoubleLinkNode[*lfuBucket[K]]) {
+	if bucket.Val.keys.size == 0 {
+		l.buckets.RemoveNode(bucket)
+	}
+}
+
+// exportState lists the keys with their access counts, lowest count first and
+// least recently used first within a count.
+func (l *TypedLFUEvictionPolicy[K]) exportState() policyState[K] {
+	var s policyState[K]
+	for bucket := l.buckets.tail; bucket != nil; bucket = bucket.Prev {
+		for node := bucket.Val.keys.tail; node != nil; node = node.Prev {
+			s.Keys = append(s.Keys, policyKey[K]{Key: node.Val, Count: bucket.Val.freq})
+		}
+	}
+	return s
+}
+
+// importState moves each key straight to the bucket for its count.
+func (l *TypedLFUEvictionPolicy[K]) importState(s policyState[K]) {
+	for _, k := range s.Keys {
+		entry, ok := l.lut[k.Key]
+		if !ok {
+			continue
+		}
+		entry.bucket.Val.keys.RemoveNode(entry.node)
+		l.removeIfEmpty(entry.bucket)
+		entry.bucket = l.bucket(max(k.Count, 1))
+		entry.bucket.Val.keys.AddToHead(entry.node)
+	}
+}
+
+// bucket returns the bucket for freq, adding it if there isn't one. The search
+// starts from the highest count, as keys are imported lowest count first.
+func (l *TypedLFUEvictionPolicy[K]) bucket(freq int) *TypedDoubleLinkNode[*lfuBucket[K]] {
+	next := l.buckets.head
+	for next != nil && next.Val.freq > freq {
+		next = next.Next
+	}
+	if next != nil && next.Val.freq == freq {
+		return next
+	}
+
+	bucket := &TypedDoubleLinkNode[*lfuBucket[K]]{Val: &lfuBucket[K]{freq: freq}}
+	if next == nil {
+		l.buckets.AddToTail(bucket)
+	} else {
+		l.buckets.InsertBefore(bucket, next)
+	}
+	return bucket
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+var (
+	_ EvictionPolicy              = (*LFUEvictionPolicy)(nil)
+	_ TypedEvictionPolicy[string] = (*TypedLFUEvictionPolicy[string])(nil)
+)
+
+func TestLFUEvictionOrderComparedToLRU(t *testing.T) {
+	testCases := []struct {
+		name     string
+		accesses []string
+		wantLFU  []string
+		wantLRU  []string
+	}{
+		{
+			name:     "single access each",
+			accesses: []string{"a", "b", "c"},
+			wantLFU:  []string{"a", "b", "c"},
+			wantLRU:  []string{"a", "b", "c"},
+		},
+		{
+			name:     "frequency beats recency",
+			accesses: []string{"a", "a", "a", "b", "c"},
+			wantLFU:  []string{"b", "c", "a"},
+			wantLRU:  []string{"a", "b", "c"},
+		},
+		{
+			name:     "ties broken by recency",
+			accesses: []string{"a", "b", "c", "b", "a", "c"},
+			wantLFU:  []string{"b", "a", "c"},
+			wantLRU:  []string{"b", "a", "c"},
+		},
+		{
+			name:     "hot set survives scan",
+			accesses: []string{"h1", "h2", "h1", "h2", "s1", "s2", "s3"},
+			wantLFU:  []string{"s1", "s2", "s3", "h1", "h2"},
+			wantLRU:  []string{"h1", "h2", "s1", "s2", "s3"},
+		},
+		{
+			name:     "gaps between frequencies",
+			accesses: []string{"a", "a", "a", "a", "b", "b", "c"},
+			wantLFU:  []string{"c", "b", "a"},
+			wantLRU:  []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policies := []struct {
+				name   string
+				policy TypedEvictionPolicy[string]
+				want   []string
+			}{
+				{"lfu", NewTypedLFUEvictionPolicy[string](), tc.wantLFU},
+				{"lru", NewTypedLRUEvictionPolicy[string](), tc.wantLRU},
+			}
+			for _, p := range policies {
+				for _, key := range tc.accesses {
+					p.policy.ItemAccessed(key)
+				}
+				var got []string
+				for range p.want {
+					got = append(got, p.policy.Evict())
+				}
+				if fmt.Sprint(got) != fmt.Sprint(p.want) {
+					t.Errorf("%s eviction order: got %v, want %v", p.name, got, p.want)
+				}
+				if key := p.policy.Evict(); key != "" {
+					t.Errorf("%s Evict on empty policy: got %q, want zero value", p.name, key)
+				}
+			}
+		})
+	}
+}
+
+func TestLFUEvictionPolicyEvictThenAccess(t *testing.T) {
+	lfu := NewTypedLFUEvictionPolicy[int]()
+	for _, key := range []int{1, 1, 2, 2, 3} {
+		lfu.ItemAccessed(key)
+	}
+
+	if got := lfu.Evict(); got != 3 {
+		t.Fatalf("Evict: got %d, want 3", got)
+	}
+	// Evicting the last key with the lowest count exposes the next bucket.
+	if got := lfu.Evict(); got != 1 {
+		t.Fatalf("Evict: got %d, want 1", got)
+	}
+	// A re-added key starts over with a count of one.
+	lfu.ItemAccessed(1)
+	if got := lfu.Evict(); got != 1 {
+		t.Fatalf("Evict: got %d, want 1", got)
+	}
+	if got := lfu.Evict(); got != 2 {
+		t.Fatalf("Evict: got %d, want 2", got)
+	}
+}
+
+func BenchmarkLFUEvictionPolicy(b *testing.B) {
+	lfu := NewTypedLFUEvictionPolicy[int]()
+	for i := 0; i < benchCacheSize; i++ {
+		lfu.ItemAccessed(i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		lfu.ItemAccessed(n % benchCacheSize)
+		if n%8 == 0 {
+			lfu.ItemAccessed(lfu.Evict())
+		}
+	}
+}
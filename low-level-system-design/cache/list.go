@@ -0,0 +1,112 @@
+package cache
+
+// DoubleLinkNode and DoublyLinkedList hold arbitrary values.
+type (
+	DoubleLinkNode   = TypedDoubleLinkNode[any]
+	DoublyLinkedList = TypedDoublyLinkedList[any]
+)
+
+type TypedDoubleLinkNode[T any] struct {
+	// Key isn't used by this package. It is kept so code written against
+	// the original DoubleLinkNode still compiles.
+	Key  int
+	Val  T
+	Next *TypedDoubleLinkNode[T]
+	Prev *TypedDoubleLinkNode[T]
+}
+
+type TypedDoublyLinkedList[T any] struct {
+	head *TypedDoubleLinkNode[T]
+	tail *TypedDoubleLinkNode[T]
+	size int
+}
+
+func (dll *TypedDoublyLinkedList[T]) RemoveNode(node *TypedDoubleLinkNode[T]) {
+	if node == nil {
+		return
+	}
+
+	if node.Prev != nil {
+		node.Prev.Next = node.Next
+	} else {
+		dll.head = node.Next
+	}
+
+	if node.Next != nil {
+		node.Next.Prev = node.Prev
+	} else {
+		dll.tail = node.Prev
+	}
+
+	node.Prev = nil
+	node.Next = nil
+
+	dll.size--
+}
+
+func (dll *TypedDoublyLinkedList[T]) AddItemToHead(item T) *TypedDoubleLinkNode[T] {
+	node := &TypedDoubleLinkNode[T]{Val: item}
+	dll.AddToHead(node)
+	return node
+}
+
+func (dll *TypedDoublyLinkedList[T]) AddToHead(node *TypedDoubleLinkNode[T]) {
+	if dll.head == nil {
+		dll.head = node
+		dll.tail = node
+	} else {
+		node.Next = dll.head
+		dll.head.Prev = node
+		dll.head = node
+	}
+
+	dll.size++
+}
+
+func (dll *TypedDoublyLinkedList[T]) AddToTail(node *TypedDoubleLinkNode[T]) {
+	if dll.tail == nil {
+		dll.head = node
+		dll.tail = node
+	} else {
+		node.Prev = dll.tail
+		dll.tail.Next = node
+		dll.tail = node
+	}
+
+	dll.size++
+}
+
+// InsertBefore inserts node on the head side of mark.
+func (dll *TypedDoublyLinkedList[T]) InsertBefore(node, mark *TypedDoubleLinkNode[T]) {
+	if mark.Prev == nil {
+		dll.AddToHead(node)
+		return
+	}
+
+	node.Prev = mark.Prev
+	node.Next = mark
+	mark.Prev.Next = node
+	mark.Prev = node
+
+	dll.size++
+}
+
+func (dll *TypedDoublyLinkedList[T]) RemoveTail() *TypedDoubleLinkNode[T] {
+	if dll.tail == nil {
+		return nil
+	}
+
+	prevTail := dll.tail
+	if dll.tail.Prev != nil {
+		dll.tail = dll.tail.Prev
+		dll.tail.Next = nil
+	} else {
+		dll.head = nil
+		dll.tail = nil
+	}
+	prevTail.Prev = nil
+
+	dll.size--
+
+	return prevTail
+}
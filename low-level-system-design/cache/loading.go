@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// LoadingCache works with arbitrary keys and values.
+type LoadingCache = TypedLoadingCache[any, any]
+
+// Loader fetches the value for key from wherever the cache is backed by.
+type Loader[K comparable, V any] func(key K) (V, error)
+
+// TypedLoadingCache wraps a TypedCache and fills misses by calling a loader.
+//
+// Concurrent misses for the same key share a single call to the loader, and
+// the loader's result, value or error, is returned to all of them. Loaded
+// values are added with the wrapped cache's default TTL.
+type TypedLoadingCache[K comparable, V any] struct {
+	cache  *TypedCache[K, V]
+	loader Loader[K, V]
+	config LoadingCacheConfig
+
+	// negative holds recent loader errors, if negative caching is enabled.
+	negative *TypedCache[K, error]
+	random   func() float64 // In [0, 1), as rand.Float64.
+
+	mu             sync.Mutex
+	calls          map[K]*loadCall[V]
+	onRefreshError func(key K, err error)
+}
+
+// loadCall is a loader call in progress or just finished.
+type loadCall[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// ErrLoaderPanicked is returned to callers waiting on a loader that panicked.
+var ErrLoaderPanicked = errors.New("loader panicked")
+
+// LoadingCacheConfig is needed to create a new loading cache.
+type LoadingCacheConfig struct {
+	// NegativeTTL is how long a loader error is remembered. While it is,
+	// Get returns the error without calling the loader again.
+	// Default is zero, meaning errors are not cached.
+	NegativeTTL time.Duration
+	// NegativeCapacity is the number of loader errors remembered at once.
+	// Default is 1024.
+	NegativeCapacity int
+	// RefreshAhead is how long before a key expires a Get starts reloading
+	// it in the background. The current value is returned meanwhile. Only
+	// keys with a TTL are refreshed.
+	// Default is zero, meaning keys are only loaded once they have expired.
+	RefreshAhead time.Duration
+	// EarlyRefreshBeta enables probabilistic early refresh, known as XFetch,
+	// to spread out the reloads of keys that expire together. A Get of a
+	// key with a TTL reloads it early with a probability that rises as
+	// expiry nears, and sooner for keys that are slow to load. Larger
+	// values refresh earlier; 1 is a good start. The caller that wins the
+	// draw starts a background reload, and it and every other caller get
+	// the current value until the reload lands. A key's load time is kept
+	// with its entry, so keys that weren't put by the loader, or were
+	// restored from a snapshot, aren't refreshed early.
+	// Default is zero, meaning keys aren't refreshed early this way.
+	EarlyRefreshBeta float64
+}
+
+// LoadingCacheOption is used to configure a new loading cache.
+type LoadingCacheOption func(*LoadingCacheConfig)
+
+// WithNegativeTTL caches loader errors for ttl.
+func WithNegativeTTL(ttl time.Duration) LoadingCacheOption {
+	return func(c *LoadingCacheConfig) {
+		c.NegativeTTL = ttl
+	}
+}
+
+// WithNegativeCapacity sets the number of loader errors remembered at once.
+func WithNegativeCapacity(n int) LoadingCacheOption {
+	return func(c *LoadingCacheConfig) {
+		c.NegativeCapacity = n
+	}
+}
+
+// WithEarlyRefresh enables probabilistic early refresh with the given beta.
+func WithEarlyRefresh(beta float64) LoadingCacheOption {
+	return func(c *LoadingCacheConfig) {
+		c.EarlyRefreshBeta = beta
+	}
+}
+
+// WithRefreshAhead reloads keys in the background once they are within d of expiring.
+func WithRefreshAhead(d time.Duration) LoadingCacheOption {
+	return func(c *LoadingCacheConfig) {
+		c.RefreshAhead = d
+	}
+}
+
+func NewLoadingCache(cache *Cache, loader Loader[any, any], opts ...LoadingCacheOption) *LoadingCache {
+	return NewTypedLoadingCache(cache, loader, opts...)
+}
+
+// NewTypedLoadingCache creates a loading cache that stores values in cache
+// and fills misses with loader.
+func NewTypedLoadingCache[K comparable, V any](cache *TypedCache[K, V], loader Loader[K, V], opts ...LoadingCacheOption) *TypedLoadingCache[K, V] {
+	const defaultNegativeCapacity = 1024
+	c := LoadingCacheConfig{NegativeCapacity: defaultNegativeCapacity}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	lc := &TypedLoadingCache[K, V]{
+		cache:  cache,
+		loader: loader,
+		config: c,
+		calls:  make(map[K]*loadCall[V]),
+		random: rand.Float64,
+	}
+	if c.NegativeTTL > 0 {
+		lc.negative = NewTypedCache[K, error](
+			NewTypedMemoryStorage[K, error](c.NegativeCapacity),
+			NewTypedLRUEvictionPolicy[K](),
+			WithDefaultTTL(c.NegativeTTL),
+			WithClock(cache.config.Clock),
+		)
+	}
+	return lc
+}
+
+// Get returns the value for key, calling the loader if it isn't cached. A hit
+// never waits on the loader: refreshes, ahead of expiry or early, run in the
+// background.
+func (c *TypedLoadingCache[K, V]) Get(key K) (V, error) {
+	val, expiry, err := c.cache.getWithExpiry(key)
+	if err == nil {
+		if c.refreshDue(expiry.at) || c.earlyRefreshDue(expiry.at, expiry.loadTime) {
+			c.refresh(key)
+		}
+		return val, nil
+	}
+
+	if c.negative != nil {
+		if lerr, err := c.negative.Get(key); err == nil {
+			var zero V
+			return zero, lerr
+		}
+	}
+
+	call, started := c.startLoad(key)
+	if started {
+		c.load(key, call)
+	} else {
+		<-call.done
+	}
+	return call.val, call.err
+}
+
+// Cache returns the underlying cache.
+func (c *TypedLoadingCache[K, V]) Cache() *TypedCache[K, V] {
+	return c.cache
+}
+
+func (c *TypedLoadingCache[K, V]) refreshDue(expiresAt time.Time) bool {
+	if c.config.RefreshAhead <= 0 || expiresAt.IsZero() {
+		return false
+	}
+	return !c.cache.config.Clock().Before(expiresAt.Add(-c.config.RefreshAhead))
+}
+
+// earlyRefreshDue draws whether to reload a key early, following XFetch: it is
+// due once now - loadTime * beta * ln(rand) reaches the expiry time.
+func (c *TypedLoadingCache[K, V]) earlyRefreshDue(expiresAt time.Time, loadTime time.Duration) bool {
+	if c.config.EarlyRefreshBeta <= 0 || expiresAt.IsZero() || loadTime <= 0 {
+		return false
+	}
+	gap := float64(loadTime) * c.config.EarlyRefreshBeta * -math.Log(1-c.random())
+	return float64(expiresAt.Sub(c.cache.config.Clock())) <= gap
+}
+
+// OnRefreshError sets fn to be called with the error of every background
+// refresh that fails, replacing any earlier hook. A loader that panics during
+// a refresh is reported with ErrLoaderPanicked rather than crashing the
+// process, since there is no caller for the panic to reach. fn runs on the
+// refresh's goroutine.
+func (c *TypedLoadingCache[K, V]) OnRefreshError(fn func(key K, err error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRefreshError = fn
+}
+
+// refresh reloads key in the background unless a load is already running.
+func (c *TypedLoadingCache[K, V]) refresh(key K) {
+	call, started := c.startLoad(key)
+	if !started {
+		return
+	}
+	go func() {
+		defer func() {
+			// load has already turned a panic into call.err.
+			recover()
+			c.mu.Lock()
+			fn := c.onRefreshError
+			c.mu.Unlock()
+			if fn != nil && call.err != nil {
+				fn(key, call.err)
+			}
+		}()
+		c.load(key, call)
+	}()
+}
+
+// startLoad returns the in-flight call for key, or registers a new one and
+// reports that the caller must run it.
+func (c *TypedLoadingCache[K, V]) startLoad(key K) (*loadCall[V], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if call, ok := c.calls[key]; ok {
+		return call, false
+	}
+	call := &loadCall[V]{done: make(chan struct{})}
+	c.calls[key] = call
+	return call, true
+}
+
+// load calls the loader, caches the result and wakes every waiter. If the
+// loader panics, waiters get ErrLoaderPanicked and the panic carries on in
+// the goroutine that ran it, unless that is a background refresh.
+func (c *TypedLoadingCache[K, V]) load(key K, call *loadCall[V]) {
+	start, began := time.Now(), c.cache.config.Clock()
+	defer func() {
+		if r := recover(); r != nil {
+			call.err = fmt.Errorf("%w: %v", ErrLoaderPanicked, r)
+			c.cache.recordLoad(time.Since(start), call.err)
+			c.finish(key, call)
+			panic(r)
+		}
+	}()
+
+	call.val, call.err = c.loader(key)
+	c.cache.recordLoad(time.Since(start), call.err)
+	if call.err == nil {
+		// Timed with the cache's clock, which expiry is measured against.
+		// The value is still returned if it couldn't be cached.
+		c.cache.Put(key, call.val, withLoadTime(c.cache.config.Clock().Sub(began)))
+	} else if c.negative != nil {
+		c.negative.Put(key, call.err)
+	}
+	c.finish(key, call)
+}
+
+func (c *TypedLoadingCache[K, V]) finish(key K, call *loadCall[V]) {
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// Close closes the underlying caches.
+func (c *TypedLoadingCache[K, V]) Close() error {
+	if c.negative != nil {
+		c.negative.Close()
+	}
+	return c.cache.Close()
+}
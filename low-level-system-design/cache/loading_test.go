@@ -0,0 +1,343 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadingCacheLoadsOnMiss(t *testing.T) {
+	var calls atomic.Int32
+	c := NewTypedLoadingCache(newTTLCache(10, newFakeClock()), func(key string) (int, error) {
+		calls.Add(1)
+		return len(key), nil
+	})
+
+	for i := 0; i < 3; i++ {
+		got, err := c.Get("abc")
+		if err != nil || got != 3 {
+			t.Fatalf("Get: got %d, %v, want 3, nil", got, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+}
+
+func TestLoadingCacheCollapsesConcurrentMisses(t *testing.T) {
+	const waiters = 50
+
+	loaderErr := errors.New("backend down")
+
+	testCases := []struct {
+		name    string
+		val     int
+		err     error
+		wantErr error
+	}{
+		{"value", 42, nil, nil},
+		{"error", 0, loaderErr, loaderErr},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls, started atomic.Int32
+			release := make(chan struct{})
+			c := NewTypedLoadingCache(newTTLCache(10, newFakeClock()), func(key string) (int, error) {
+				calls.Add(1)
+				<-release
+				return tc.val, tc.err
+			})
+
+			var wg sync.WaitGroup
+			errs := make(chan error, waiters)
+			for i := 0; i < waiters; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					started.Add(1)
+					got, err := c.Get("k")
+					if got != tc.val {
+						t.Errorf("Get: got %d, want %d", got, tc.val)
+					}
+					errs <- err
+				}()
+			}
+
+			// Give every goroutine time to join the in-flight load before finishing it.
+			waitFor(t, func() bool { return started.Load() == waiters })
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("Get: got error %v, want %v", err, tc.wantErr)
+				}
+			}
+			if n := calls.Load(); n != 1 {
+				t.Errorf("loader called %d times, want 1", n)
+			}
+		})
+	}
+}
+
+func TestLoadingCacheErrorsAreNotCachedByDefault(t *testing.T) {
+	var calls atomic.Int32
+	c := NewTypedLoadingCache(newTTLCache(10, newFakeClock()), func(key string) (int, error) {
+		calls.Add(1)
+		return 0, errors.New("not found")
+	})
+
+	c.Get("k")
+	c.Get("k")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader called %d times, want 2", n)
+	}
+}
+
+func TestLoadingCacheNegativeCaching(t *testing.T) {
+	clock := newFakeClock()
+	errNotFound := errors.New("not found")
+	var calls atomic.Int32
+	c := NewTypedLoadingCache(newTTLCache(10, clock), func(key string) (int, error) {
+		calls.Add(1)
+		return 0, errNotFound
+	}, WithNegativeTTL(time.Minute))
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get("k"); !errors.Is(err, errNotFound) {
+			t.Fatalf("Get: got %v, want %v", err, errNotFound)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("loader called %d times within negative TTL, want 1", n)
+	}
+
+	clock.Advance(time.Minute)
+	c.Get("k")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader called %d times after negative TTL, want 2", n)
+	}
+}
+
+func TestLoadingCacheRefreshAhead(t *testing.T) {
+	clock := newFakeClock()
+	var version atomic.Int32
+	loaded := make(chan struct{}, 10)
+	c := NewTypedLoadingCache(newTTLCache(10, clock, WithDefaultTTL(time.Minute)), func(key string) (int, error) {
+		defer func() { loaded <- struct{}{} }()
+		return int(version.Add(1)), nil
+	}, WithRefreshAhead(10*time.Second))
+
+	if got, _ := c.Get("k"); got != 1 {
+		t.Fatalf("Get: got %d, want 1", got)
+	}
+	<-loaded
+
+	// Outside the refresh window nothing is reloaded.
+	clock.Advance(49 * time.Second)
+	if got, _ := c.Get("k"); got != 1 {
+		t.Fatalf("Get before refresh window: got %d, want 1", got)
+	}
+	select {
+	case <-loaded:
+		t.Fatal("loader called before refresh window")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// Inside the window the stale value is returned and a reload starts.
+	clock.Advance(time.Second)
+	if got, _ := c.Get("k"); got != 1 {
+		t.Fatalf("Get in refresh window: got %d, want the current value 1", got)
+	}
+	<-loaded
+	waitFor(t, func() bool {
+		got, _ := c.Get("k")
+		return got == 2
+	})
+
+	// The refreshed value has a fresh TTL.
+	clock.Advance(30 * time.Second)
+	if got, err := c.Get("k"); err != nil || got != 2 {
+		t.Errorf("Get after refresh: got %d, %v, want 2, nil", got, err)
+	}
+}
+
+// newEarlyRefreshCache returns a loading cache whose loader takes ten seconds
+// of clock time, under a one minute TTL.
+func newEarlyRefreshCache(clock *fakeClock, loader func() int) *TypedLoadingCache[string, int] {
+	return NewTypedLoadingCache(newTTLCache(10, clock, WithDefaultTTL(time.Minute)), func(key string) (int, error) {
+		clock.Advance(10 * time.Second)
+		return loader(), nil
+	}, WithEarlyRefresh(1))
+}
+
+func TestLoadingCacheEarlyRefresh(t *testing.T) {
+	clock := newFakeClock()
+	var version atomic.Int32
+	c := newEarlyRefreshCache(clock, func() int { return int(version.Add(1)) })
+	// Draw a gap of three load times, 30 seconds, every time.
+	c.random = func() float64 { return 1 - math.Exp(-3) }
+
+	c.Get("k") // Expires in 60s.
+	clock.Advance(20 * time.Second)
+	if got, _ := c.Get("k"); got != 1 {
+		t.Fatalf("Get 40s before expiry: got %d, want 1", got)
+	}
+
+	// Within the gap, the caller gets the current value and a reload starts.
+	clock.Advance(15 * time.Second)
+	if got, _ := c.Get("k"); got != 1 {
+		t.Errorf("Get 25s before expiry: got %d, want the current 1", got)
+	}
+	waitFor(t, func() bool {
+		got, _ := c.Get("k")
+		return got == 2
+	})
+
+	// A draw of zero never refreshes early.
+	c.random = func() float64 { return 0 }
+	clock.Advance(59 * time.Second)
+	if got, _ := c.Get("k"); got != 2 {
+		t.Errorf("Get 1s before expiry with a zero draw: got %d, want 2", got)
+	}
+}
+
+func TestLoadingCacheEarlyRefreshProbability(t *testing.T) {
+	clock := newFakeClock()
+	c := newEarlyRefreshCache(clock, func() int { return 1 })
+	c.random = rand.New(rand.NewSource(1)).Float64
+	expiresAt := clock.Now().Add(time.Minute)
+
+	// XFetch refreshes with probability exp(-left / (loadTime * beta)).
+	const draws = 10_000
+	prev := -1.0
+	for _, left := range []time.Duration{40 * time.Second, 20 * time.Second, 10 * time.Second, 2 * time.Second} {
+		due := 0
+		for i := 0; i < draws; i++ {
+			if c.earlyRefreshDue(clock.Now().Add(left), 10*time.Second) {
+				due++
+			}
+		}
+		got := float64(due) / draws
+		if want := math.Exp(-left.Seconds() / 10); math.Abs(got-want) > 0.02 {
+			t.Errorf("%v before expiry: refreshed %.3f of the time, want %.3f", left, got, want)
+		}
+		if got <= prev {
+			t.Errorf("%v before expiry: probability %.3f didn't rise from %.3f", left, got, prev)
+		}
+		prev = got
+	}
+
+	if c.earlyRefreshDue(expiresAt, 0) {
+		t.Errorf("key with no load time refreshed early")
+	}
+}
+
+func TestLoadingCacheEarlyRefreshLoadTimeKeptWithEntry(t *testing.T) {
+	clock := newFakeClock()
+	c := newEarlyRefreshCache(clock, func() int { return 1 })
+	c.Get("k")
+	if _, expiry, _ := c.cache.getWithExpiry("k"); expiry.loadTime != 10*time.Second {
+		t.Errorf("load time after loading: got %v, want 10s", expiry.loadTime)
+	}
+
+	// A value put directly wasn't loaded, so it isn't refreshed early.
+	c.Cache().Put("k", 2)
+	if _, expiry, _ := c.cache.getWithExpiry("k"); expiry.loadTime != 0 {
+		t.Errorf("load time after Put: got %v, want 0", expiry.loadTime)
+	}
+}
+
+func TestLoadingCacheEarlyRefreshDoesNotBlock(t *testing.T) {
+	clock := newFakeClock()
+	var version atomic.Int32
+	release := make(chan struct{})
+	refreshing := make(chan struct{})
+	c := newEarlyRefreshCache(clock, func() int {
+		if v := version.Add(1); v > 1 {
+			close(refreshing)
+			<-release
+			return int(v)
+		}
+		return 1
+	})
+	c.random = func() float64 { return 0.999999 } // Always due.
+	c.Get("k")
+
+	// The caller that wins the draw gets the current value at once, as do
+	// the others while the reload is running, and only one reload starts.
+	for i := 0; i < 3; i++ {
+		if got, _ := c.Get("k"); got != 1 {
+			t.Errorf("Get during the refresh: got %d, want 1", got)
+		}
+	}
+	<-refreshing
+	close(release)
+	waitFor(t, func() bool {
+		got, _ := c.Get("k")
+		return got == 2
+	})
+	if n := version.Load(); n != 2 {
+		t.Errorf("loader called %d times, want 2", n)
+	}
+}
+
+func TestLoadingCacheLoaderPanic(t *testing.T) {
+	c := NewTypedLoadingCache(newTTLCache(10, newFakeClock()), func(key string) (int, error) {
+		panic("boom")
+	})
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, want boom", r)
+			}
+		}()
+		c.Get("k")
+	}()
+
+	// The failed call must not be left in flight.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.calls) != 0 {
+		t.Errorf("%d calls still in flight after panic", len(c.calls))
+	}
+}
+
+func TestLoadingCacheRefreshPanic(t *testing.T) {
+	clock := newFakeClock()
+	var calls atomic.Int32
+	c := NewTypedLoadingCache(newTTLCache(10, clock, WithDefaultTTL(time.Minute)), func(key string) (int, error) {
+		if calls.Add(1) > 1 {
+			panic("boom")
+		}
+		return 1, nil
+	}, WithRefreshAhead(10*time.Second))
+	errs := make(chan error, 1)
+	c.OnRefreshError(func(key string, err error) { errs <- err })
+
+	c.Get("k")
+	clock.Advance(55 * time.Second)
+	if got, err := c.Get("k"); err != nil || got != 1 {
+		t.Fatalf("Get in refresh window: got %d, %v, want 1, nil", got, err)
+	}
+
+	// The panic is reported instead of crashing the process, and the current
+	// value stays cached.
+	if err := <-errs; !errors.Is(err, ErrLoaderPanicked) {
+		t.Errorf("refresh error: got %v, want ErrLoaderPanicked", err)
+	}
+	if got, err := c.Cache().Get("k"); err != nil || got != 1 {
+		t.Errorf("Get after failed refresh: got %d, %v, want 1, nil", got, err)
+	}
+	if n := c.Cache().Stats().LoadFailures.Count; n != 1 {
+		t.Errorf("load failures: got %d, want 1", n)
+	}
+}
@@ -0,0 +1,74 @@
+package cache
+
+// LRUEvictionPolicy tracks arbitrary keys.
+type LRUEvictionPolicy = TypedLRUEvictionPolicy[any]
+
+type TypedLRUEvictionPolicy[K comparable] struct {
+	dll *TypedDoublyLinkedList[K]
+	lut map[K]*TypedDoubleLinkNode[K]
+}
+
+func NewLRUEvictionPolicy() *LRUEvictionPolicy {
+	return NewTypedLRUEvictionPolicy[any]()
+}
+
+func NewTypedLRUEvictionPolicy[K comparable]() *TypedLRUEvictionPolicy[K] {
+	return &TypedLRUEvictionPolicy[K]{
+		dll: new(TypedDoublyLinkedList[K]),
+		lut: make(map[K]*TypedDoubleLinkNode[K]),
+	}
+}
+
+func (l *TypedLRUEvictionPolicy[K]) ItemAccessed(item K) {
+	if node, ok := l.lut[item]; ok {
+		l.dll.RemoveNode(node)
+		l.dll.AddToHead(node)
+	} else {
+		node := l.dll.AddItemToHead(item)
+		l.lut[item] = node
+	}
+}
+
+// Victim returns the key Evict would remove without removing it.
+func (l *TypedLRUEvictionPolicy[K]) Victim() (K, bool) {
+	if l.dll.tail == nil {
+		var zero K
+		return zero, false
+	}
+	return l.dll.tail.Val, true
+}
+
+// Evict removes the least recently used key and returns it, or the zero value
+// if no keys are tracked.
+func (l *TypedLRUEvictionPolicy[K]) Evict() K {
+	node := l.dll.RemoveTail()
+	if node == nil {
+		var zero K
+		return zero
+	}
+
+	delete(l.lut, node.Val)
+	return node.Val
+}
+
+func (l *TypedLRUEvictionPolicy[K]) Remove(item K) {
+	if node, ok := l.lut[item]; ok {
+		l.dll.RemoveNode(node)
+		delete(l.lut, item)
+	}
+}
+
+// exportState lists the keys least recently used first.
+func (l *TypedLRUEvictionPolicy[K]) exportState() policyState[K] {
+	var s policyState[K]
+	for node := l.dll.tail; node != nil; node = node.Prev {
+		s.Keys = append(s.Keys, policyKey[K]{Key: node.Val})
+	}
+	return s
+}
+
+func (l *TypedLRUEvictionPolicy[K]) importState(s policyState[K]) {
+	for _, k := range s.Keys {
+		l.ItemAccessed(k.Key)
+	}
+}
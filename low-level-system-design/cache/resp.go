@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Limits on what a client may send, so a bad request can't make the server
+// allocate without bound. Memory for arguments is only allocated as their
+// bytes arrive, so a client can't reserve it by claiming a large length.
+const (
+	maxRESPArgs    = 1024 * 1024
+	maxRESPBulkLen = 32 * 1024 * 1024
+	maxRESPInline  = 64 * 1024
+)
+
+// respProtocolError is a request that isn't valid RESP. The server reports it
+// and closes the connection, since it can't tell where the next request starts.
+type respProtocolError string
+
+func (e respProtocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+// readRESPCommand reads one request, either a RESP array of bulk strings or an
+// inline command of space-separated words as typed into telnet.
+func readRESPCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxRESPArgs {
+		return nil, respProtocolError("invalid multibulk length")
+	}
+	args := make([][]byte, 0, min(max(n, 0), 16))
+	for i := 0; i < n; i++ {
+		line, err := readRESPLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, respProtocolError(fmt.Sprintf("expected '$', got %q", line))
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxRESPBulkLen {
+			return nil, respProtocolError("invalid bulk length")
+		}
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, r, int64(size)+2); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		arg := buf.Bytes()
+		if !bytes.HasSuffix(arg, []byte("\r\n")) {
+			return nil, respProtocolError("bulk string not terminated by CRLF")
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+// readRESPLine reads a line up to CRLF, or a bare LF as inline commands may
+// end with, and returns it without the line ending.
+func readRESPLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(line) > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxRESPInline {
+			return nil, respProtocolError("too big request line")
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// respWriter writes RESP2 replies.
+type respWriter struct {
+	*bufio.Writer
+}
+
+func (w respWriter) simple(s string) {
+	w.WriteByte('+')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w respWriter) error(msg string) {
+	w.WriteByte('-')
+	w.WriteString(msg)
+	w.WriteString("\r\n")
+}
+
+func (w respWriter) integer(n int64) {
+	w.WriteByte(':')
+	w.WriteString(strconv.FormatInt(n, 10))
+	w.WriteString("\r\n")
+}
+
+func (w respWriter) bulk(b []byte) {
+	w.WriteByte('$')
+	w.WriteString(strconv.Itoa(len(b)))
+	w.WriteString("\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+// null writes the null bulk string, RESP2's reply for a missing key.
+func (w respWriter) null() {
+	w.WriteString("$-1\r\n")
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// HashRing places keys on nodes by consistent hashing. Each node owns many
+// points on the ring, its virtual nodes, and a key belongs to the node owning
+// the first point at or after the key's hash. Adding or removing a node only
+// moves the keys next to its points. It is safe for concurrent use.
+type HashRing struct {
+	mu     sync.RWMutex
+	vnodes int
+	points []uint64 // Sorted.
+	owners map[uint64]string
+	nodes  map[string]struct{}
+}
+
+// NewHashRing creates an empty ring that gives each node vnodes points.
+func NewHashRing(vnodes int) *HashRing {
+	return &HashRing{
+		vnodes: max(vnodes, 1),
+		owners: make(map[uint64]string),
+		nodes:  make(map[string]struct{}),
+	}
+}
+
+func (r *HashRing) Add(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.nodes[node]; ok {
+		return
+	}
+	r.nodes[node] = struct{}{}
+	for i := 0; i < r.vnodes; i++ {
+		p := stableHash(node + "#" + strconv.Itoa(i))
+		// On the rare collision the first node keeps the point.
+		if _, taken := r.owners[p]; taken {
+			continue
+		}
+		r.owners[p] = node
+		r.points = append(r.points, p)
+	}
+	slices.Sort(r.points)
+}
+
+func (r *HashRing) Remove(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.nodes[node]; !ok {
+		return
+	}
+	delete(r.nodes, node)
+	r.points = slices.DeleteFunc(r.points, func(p uint64) bool {
+		if r.owners[p] != node {
+			return false
+		}
+		delete(r.owners, p)
+		return true
+	})
+}
+
+// Nodes returns up to n distinct nodes for key, walking clockwise from its
+// hash. The first is the key's owner and the rest hold its replicas.
+func (r *HashRing) Nodes(key any, n int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	n = min(n, len(r.nodes))
+	if n <= 0 {
+		return nil
+	}
+
+	h := stableHash(key)
+	start, _ := slices.BinarySearch(r.points, h)
+	nodes := make([]string, 0, n)
+	for i := 0; len(nodes) < n; i++ {
+		node := r.owners[r.points[(start+i)%len(r.points)]]
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Members returns the nodes on the ring, sorted.
+func (r *HashRing) Members() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	slices.Sort(nodes)
+	return nodes
+}
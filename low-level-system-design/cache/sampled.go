@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+)
+
+// SampledLRUEvictionPolicy tracks arbitrary keys.
+type SampledLRUEvictionPolicy = TypedSampledLRUEvictionPolicy[any]
+
+// TypedSampledLRUEvictionPolicy approximates LRU the way Redis does: it
+// evicts the least recently used of a few keys picked at random. More samples
+// get closer to LRU at the cost of slower evictions.
+//
+// Keys are kept in a slice with the logical time of their last access, so an
+// access is a single store and a key costs no list pointers, using less
+// memory than LRUEvictionPolicy. With at least as many samples as keys, every
+// key is checked and the policy is exactly LRU.
+type TypedSampledLRUEvictionPolicy[K comparable] struct {
+	entries []sampledEntry[K]
+	lut     map[K]int // Index of each key's entry.
+	samples int
+	now     uint64 // Logical clock, ticking on every access.
+	rand    *rand.Rand
+
+	// victim is the next key to evict, drawn by Victim and kept until the
+	// policy changes so that Evict removes the same key.
+	victim    K
+	hasVictim bool
+}
+
+type sampledEntry[K comparable] struct {
+	key        K
+	lastAccess uint64
+}
+
+// SampledLRUConfig is needed to create a new sampled LRU policy.
+type SampledLRUConfig struct {
+	// Rand draws the keys to sample. It is only used by the policy, which
+	// isn't safe for concurrent use.
+	// Default is a source seeded at random.
+	Rand *rand.Rand
+}
+
+// SampledLRUOption is used to configure a new sampled LRU policy.
+type SampledLRUOption func(*SampledLRUConfig)
+
+// WithSampledRand sets the source the keys to sample are drawn from, so that
+// evictions can be reproduced.
+func WithSampledRand(r *rand.Rand) SampledLRUOption {
+	return func(c *SampledLRUConfig) {
+		c.Rand = r
+	}
+}
+
+func NewSampledLRUEvictionPolicy(samples int, opts ...SampledLRUOption) *SampledLRUEvictionPolicy {
+	return NewTypedSampledLRUEvictionPolicy[any](samples, opts...)
+}
+
+// NewTypedSampledLRUEvictionPolicy creates a policy that evicts the least
+// recently used of samples random keys. Redis defaults to 5.
+func NewTypedSampledLRUEvictionPolicy[K comparable](samples int, opts ...SampledLRUOption) *TypedSampledLRUEvictionPolicy[K] {
+	var c SampledLRUConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.Rand == nil {
+		c.Rand = rand.New(rand.NewSource(rand.Int63()))
+	}
+
+	return &TypedSampledLRUEvictionPolicy[K]{
+		lut:     make(map[K]int),
+		samples: max(samples, 1),
+		rand:    c.Rand,
+	}
+}
+
+func (s *TypedSampledLRUEvictionPolicy[K]) ItemAccessed(item K) {
+	s.hasVictim = false
+	s.now++
+	if i, ok := s.lut[item]; ok {
+		s.entries[i].lastAccess = s.now
+		return
+	}
+	s.entries = append(s.entries, sampledEntry[K]{key: item, lastAccess: s.now})
+	s.lut[item] = len(s.entries) - 1
+}
+
+// Victim returns the key Evict would remove without removing it.
+func (s *TypedSampledLRUEvictionPolicy[K]) Victim() (K, bool) {
+	if len(s.entries) == 0 {
+		var zero K
+		return zero, false
+	}
+	if !s.hasVictim {
+		var oldest sampledEntry[K]
+		if s.samples >= len(s.entries) {
+			oldest = slices.MinFunc(s.entries, func(a, b sampledEntry[K]) int {
+				return cmp.Compare(a.lastAccess, b.lastAccess)
+			})
+		} else {
+			oldest = s.entries[s.rand.Intn(len(s.entries))]
+			for n := 1; n < s.samples; n++ {
+				if e := s.entries[s.rand.Intn(len(s.entries))]; e.lastAccess < oldest.lastAccess {
+					oldest = e
+				}
+			}
+		}
+		s.victim, s.hasVictim = oldest.key, true
+	}
+	return s.victim, true
+}
+
+// Evict removes the least recently used of a random sample of keys and
+// returns it, or the zero value if no keys are tracked.
+func (s *TypedSampledLRUEvictionPolicy[K]) Evict() K {
+	key, ok := s.Victim()
+	if ok {
+		s.Remove(key)
+	}
+	return key
+}
+
+// Remove drops item by moving the last entry into its place.
+func (s *TypedSampledLRUEvictionPolicy[K]) Remove(item K) {
+	i, ok := s.lut[item]
+	if !ok {
+		return
+	}
+	s.hasVictim = false
+	last := len(s.entries) - 1
+	s.entries[i] = s.entries[last]
+	s.lut[s.entries[i].key] = i
+	s.entries[last] = sampledEntry[K]{}
+	s.entries = s.entries[:last]
+	delete(s.lut, item)
+}
+
+// exportState lists the keys from least to most recently used.
+func (s *TypedSampledLRUEvictionPolicy[K]) exportState() policyState[K] {
+	sorted := slices.Clone(s.entries)
+	slices.SortFunc(sorted, func(a, b sampledEntry[K]) int {
+		return cmp.Compare(a.lastAccess, b.lastAccess)
+	})
+	state := policyState[K]{Keys: make([]policyKey[K], len(sorted))}
+	for i, e := range sorted {
+		state.Keys[i] = policyKey[K]{Key: e.key}
+	}
+	return state
+}
+
+func (s *TypedSampledLRUEvictionPolicy[K]) importState(state policyState[K]) {
+	for _, k := range state.Keys {
+		s.ItemAccessed(k.Key)
+	}
+}
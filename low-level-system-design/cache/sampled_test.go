@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var (
+	_ EvictionPolicy              = (*SampledLRUEvictionPolicy)(nil)
+	_ TypedEvictionPolicy[string] = (*TypedSampledLRUEvictionPolicy[string])(nil)
+)
+
+func TestSampledLRUEvictionPolicyManySamplesIsLRU(t *testing.T) {
+	// With as many samples as keys, every key is checked.
+	s := NewTypedSampledLRUEvictionPolicy[string](4)
+	for _, key := range []string{"a", "b", "c", "d", "b", "a"} {
+		s.ItemAccessed(key)
+	}
+	for _, want := range []string{"c", "d", "b", "a"} {
+		if got := s.Evict(); got != want {
+			t.Fatalf("Evict: got %q, want %q", got, want)
+		}
+	}
+	if got, ok := s.Victim(); ok {
+		t.Errorf("Victim on empty policy: got %q, true", got)
+	}
+}
+
+func TestSampledLRUEvictionPolicyEvictsOldSamples(t *testing.T) {
+	const (
+		keys    = 100
+		samples = 5
+		trials  = 5_000
+	)
+	r := rand.New(rand.NewSource(1))
+	older := 0
+	for range trials {
+		s := NewTypedSampledLRUEvictionPolicy[int](samples, WithSampledRand(r))
+		for key := range keys {
+			s.ItemAccessed(key)
+		}
+		if s.Evict() < keys/2 {
+			older++
+		}
+	}
+
+	// The victim is from the older half unless all samples are from the
+	// newer one.
+	got, want := float64(older)/trials, 1-math.Pow(0.5, samples)
+	if math.Abs(got-want) > 0.01 {
+		t.Errorf("evicted from the older half %.3f of the time, want %.3f", got, want)
+	}
+}
+
+func TestSampledLRUEvictionPolicyRemove(t *testing.T) {
+	s := NewTypedSampledLRUEvictionPolicy[int](5)
+	for key := 0; key < 10; key++ {
+		s.ItemAccessed(key)
+	}
+	for _, key := range []int{0, 9, 4, 4} {
+		s.Remove(key)
+	}
+
+	if n := len(s.entries); n != 7 {
+		t.Fatalf("tracking %d keys, want 7", n)
+	}
+	for i, e := range s.entries {
+		if s.lut[e.key] != i {
+			t.Errorf("key %d indexed at %d, stored at %d", e.key, s.lut[e.key], i)
+		}
+	}
+}
+
+func TestSampledLRUEvictionPolicyVictimMatchesEvict(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := NewTypedSampledLRUEvictionPolicy[int](3)
+	for i := 0; i < 10_000; i++ {
+		switch key := r.Intn(32); r.Intn(4) {
+		case 0:
+			victim, ok := s.Victim()
+			if got := s.Evict(); ok && got != victim {
+				t.Fatalf("step %d: Evict got %d, Victim said %d", i, got, victim)
+			}
+		case 1:
+			s.Remove(key)
+		default:
+			s.ItemAccessed(key)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerConfig is needed to create a new RESP server.
+type ServerConfig struct {
+	// MaxConns is the number of clients served at once. Further clients are
+	// sent an error and disconnected, as Redis does.
+	// Default is 1024.
+	MaxConns int
+	// IdleTimeout disconnects clients that send nothing for this long.
+	// Default is zero, meaning clients are never disconnected for idling.
+	IdleTimeout time.Duration
+}
+
+// ServerOption is used to configure a new RESP server.
+type ServerOption func(*ServerConfig)
+
+// WithMaxConns sets the number of clients served at once.
+func WithMaxConns(n int) ServerOption {
+	return func(c *ServerConfig) {
+		c.MaxConns = n
+	}
+}
+
+// WithIdleTimeout sets how long a client may send nothing before it is
+// disconnected.
+func WithIdleTimeout(d time.Duration) ServerOption {
+	return func(c *ServerConfig) {
+		c.IdleTimeout = d
+	}
+}
+
+// ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("server closed")
+
+// Server serves a Cache over TCP with a subset of the Redis protocol, RESP2,
+// so clients in any language can use it. It supports GET, SET with EX, DEL,
+// EXISTS, TTL, INFO, PING and QUIT.
+//
+// Keys are strings, and SET stores values as strings. GET replies with string
+// and []byte values as they are and formats others with fmt.Sprint.
+//
+// Clients may pipeline requests: replies are buffered while more requests are
+// waiting to be read and written together.
+type Server struct {
+	cache  *Cache
+	config ServerConfig
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func NewServer(cache *Cache, opts ...ServerOption) *Server {
+	const defaultMaxConns = 1024
+	c := ServerConfig{MaxConns: defaultMaxConns}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &Server{
+		cache:     cache,
+		config:    c,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve accepts clients on l until Close is called, then returns
+// ErrServerClosed. It closes l when it returns.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return fmt.Errorf("accepting connection: %w", err)
+		}
+		if !s.track(conn) {
+			conn.Write([]byte("-ERR max number of clients reached\r\n"))
+			conn.Close()
+			continue
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// track registers conn unless the server is closed or full.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || len(s.conns) >= s.config.MaxConns {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	conn.Close()
+	s.wg.Done()
+}
+
+// Close stops every Serve call, disconnects all clients and waits for their
+// requests to finish. It does not close the cache.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.untrack(conn)
+
+	r := bufio.NewReader(conn)
+	w := respWriter{bufio.NewWriter(conn)}
+	for {
+		// Only flush once every pipelined request has been answered.
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+			if s.config.IdleTimeout > 0 {
+				conn.SetReadDeadline(time.Now().Add(s.config.IdleTimeout))
+			}
+		}
+
+		args, err := readRESPCommand(r)
+		if err != nil {
+			var perr respProtocolError
+			if errors.As(err, &perr) {
+				w.error("ERR " + perr.Error())
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if !s.exec(w, args) {
+			w.Flush()
+			return
+		}
+	}
+}
+
+// respArity checks the number of arguments each command is given.
+var respArity = map[string]func(n int) bool{
+	"get":    func(n int) bool { return n == 1 },
+	"set":    func(n int) bool { return n >= 2 },
+	"del":    func(n int) bool { return n >= 1 },
+	"exists": func(n int) bool { return n >= 1 },
+	"ttl":    func(n int) bool { return n == 1 },
+	"info":   func(n int) bool { return n <= 1 },
+	"ping":   func(n int) bool { return n <= 1 },
+	"quit":   func(n int) bool { return true },
+}
+
+// exec runs one command and writes its reply. It returns false if the client
+// should be disconnected.
+func (s *Server) exec(w respWriter, args [][]byte) bool {
+	name := strings.ToLower(string(args[0]))
+	args = args[1:]
+
+	valid, ok := respArity[name]
+	if !ok {
+		w.error(fmt.Sprintf("ERR unknown command '%s'", truncateName(name)))
+		return true
+	}
+	if !valid(len(args)) {
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return true
+	}
+
+	switch name {
+	case "get":
+		s.get(w, string(args[0]))
+	case "set":
+		s.set(w, args)
+	case "del":
+		var n int64
+		for _, key := range args {
+			if s.cache.Delete(string(key)) == nil {
+				n++
+			}
+		}
+		w.integer(n)
+	case "exists":
+		var n int64
+		for _, key := range args {
+			if _, ok := s.cache.peekExpiry(string(key)); ok {
+				n++
+			}
+		}
+		w.integer(n)
+	case "ttl":
+		s.ttl(w, string(args[0]))
+	case "info":
+		w.bulk([]byte(s.info()))
+	case "ping":
+		if len(args) == 1 {
+			w.bulk(args[0])
+		} else {
+			w.simple("PONG")
+		}
+	case "quit":
+		w.simple("OK")
+		return false
+	}
+	return true
+}
+
+// truncateName shortens an unknown command's name for its error message.
+func truncateName(name string) string {
+	const maxLen = 128
+	if len(name) > maxLen {
+		return name[:maxLen]
+	}
+	return name
+}
+
+func (s *Server) get(w respWriter, key string) {
+	val, err := s.cache.Get(key)
+	if err != nil {
+		w.null()
+		return
+	}
+	switch v := val.(type) {
+	case string:
+		w.bulk([]byte(v))
+	case []byte:
+		w.bulk(v)
+	default:
+		w.bulk([]byte(fmt.Sprint(v)))
+	}
+}
+
+// set handles SET key value [EX seconds].
+func (s *Server) set(w respWriter, args [][]byte) {
+	var ttl time.Duration
+	switch len(args) {
+	case 2:
+	case 4:
+		if !strings.EqualFold(string(args[2]), "ex") {
+			w.error("ERR syntax error")
+			return
+		}
+		secs, err := strconv.ParseInt(string(args[3]), 10, 64)
+		if err != nil {
+			w.error("ERR value is not an integer or out of range")
+			return
+		}
+		if secs <= 0 || secs > math.MaxInt64/int64(time.Second) {
+			w.error("ERR invalid expire time in 'set' command")
+			return
+		}
+		ttl = time.Duration(secs) * time.Second
+	default:
+		w.error("ERR syntax error")
+		return
+	}
+
+	// Without EX, the key doesn't expire, as in Redis, whatever the cache's
+	// default TTL.
+	if err := s.cache.PutWithTTL(string(args[0]), string(args[1]), ttl); err != nil {
+		w.error("ERR " + err.Error())
+		return
+	}
+	w.simple("OK")
+}
+
+// ttl replies with the seconds left before key expires, -1 if it never does,
+// or -2 if it isn't cached.
+func (s *Server) ttl(w respWriter, key string) {
+	expiresAt, ok := s.cache.peekExpiry(key)
+	switch {
+	case !ok:
+		w.integer(-2)
+	case expiresAt.IsZero():
+		w.integer(-1)
+	default:
+		left := expiresAt.Sub(s.cache.config.Clock())
+		w.integer(int64((left + time.Second/2) / time.Second))
+	}
+}
+
+// info returns the INFO report, in Redis's format of sections of name:value
+// lines.
+func (s *Server) info() string {
+	s.mu.Lock()
+	clients := len(s.conns)
+	s.mu.Unlock()
+	stats := s.cache.Stats()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Clients\r\n")
+	fmt.Fprintf(&b, "connected_clients:%d\r\n", clients)
+	fmt.Fprintf(&b, "maxclients:%d\r\n", s.config.MaxConns)
+	fmt.Fprintf(&b, "\r\n# Stats\r\n")
+	fmt.Fprintf(&b, "keyspace_hits:%d\r\n", stats.Hits)
+	fmt.Fprintf(&b, "keyspace_misses:%d\r\n", stats.Misses)
+	fmt.Fprintf(&b, "evicted_keys:%d\r\n", stats.Evictions[EvictionCapacity])
+	fmt.Fprintf(&b, "expired_keys:%d\r\n", stats.Evictions[EvictionExpired])
+	fmt.Fprintf(&b, "\r\n# Keyspace\r\n")
+	fmt.Fprintf(&b, "keys:%d\r\n", stats.Size)
+	fmt.Fprintf(&b, "cost:%d\r\n", stats.Cost)
+	return b.String()
+}
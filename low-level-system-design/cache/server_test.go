@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// respClient is a minimal RESP2 client.
+type respClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialRESP(t *testing.T, addr string) *respClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return &respClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// encode formats a command as a RESP array of bulk strings.
+func (c *respClient) encode(args ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return b.String()
+}
+
+func (c *respClient) send(t *testing.T, raw string) {
+	t.Helper()
+	if _, err := c.conn.Write([]byte(raw)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+}
+
+// reply reads one reply and renders it as a string: simple strings as they
+// are, errors prefixed with "-", integers as decimal, bulk strings as their
+// contents and the null bulk string as "(nil)".
+func (c *respClient) reply(t *testing.T) string {
+	t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading reply: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+', ':':
+		return line[1:]
+	case '-':
+		return line
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return "(nil)"
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			t.Fatalf("reading bulk string: %v", err)
+		}
+		return string(buf[:n])
+	}
+	t.Fatalf("unexpected reply %q", line)
+	return ""
+}
+
+func (c *respClient) do(t *testing.T, args ...string) string {
+	t.Helper()
+	c.send(t, c.encode(args...))
+	return c.reply(t)
+}
+
+// startServer serves cache on a local port and returns its address.
+func startServer(t *testing.T, cache *Cache, opts ...ServerOption) (*Server, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	s := NewServer(cache, opts...)
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve: got %v, want ErrServerClosed", err)
+		}
+	})
+	return s, l.Addr().String()
+}
+
+func newServerCache(clock *fakeClock) *Cache {
+	return NewCache(NewMemoryStorage(100), NewLRUEvictionPolicy(), WithClock(clock.Now))
+}
+
+func TestServerCommands(t *testing.T) {
+	clock := newFakeClock()
+	_, addr := startServer(t, newServerCache(clock))
+	c := dialRESP(t, addr)
+
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "PONG"},
+		{[]string{"GET", "k"}, "(nil)"},
+		{[]string{"SET", "k", "hello world"}, "OK"},
+		{[]string{"get", "k"}, "hello world"},
+		{[]string{"TTL", "k"}, "-1"},
+		{[]string{"TTL", "missing"}, "-2"},
+		{[]string{"SET", "t", "v", "EX", "10"}, "OK"},
+		{[]string{"TTL", "t"}, "10"},
+		{[]string{"EXISTS", "k", "t", "missing", "k"}, "3"},
+		{[]string{"DEL", "k", "missing"}, "1"},
+		{[]string{"EXISTS", "k"}, "0"},
+		{[]string{"SET", "e", ""}, "OK"},
+		{[]string{"GET", "e"}, ""},
+		{[]string{"SET", "k", "v", "EX", "0"}, "-ERR invalid expire time in 'set' command"},
+		{[]string{"SET", "k", "v", "EX", "soon"}, "-ERR value is not an integer or out of range"},
+		{[]string{"SET", "k", "v", "PX", "10"}, "-ERR syntax error"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command"},
+		{[]string{"FLUSHALL"}, "-ERR unknown command 'flushall'"},
+	}
+	for _, step := range steps {
+		if got := c.do(t, step.args...); got != step.want {
+			t.Errorf("%v: got %q, want %q", step.args, got, step.want)
+		}
+	}
+
+	clock.Advance(4 * time.Second)
+	if got := c.do(t, "TTL", "t"); got != "6" {
+		t.Errorf("TTL after 4s: got %s, want 6", got)
+	}
+	c.do(t, "SET", "d", "v", "EX", "6")
+	clock.Advance(6 * time.Second)
+	if got := c.do(t, "GET", "t"); got != "(nil)" {
+		t.Errorf("GET after expiry: got %q, want (nil)", got)
+	}
+	if got := c.do(t, "DEL", "d"); got != "0" {
+		t.Errorf("DEL after expiry: got %s, want 0", got)
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	_, addr := startServer(t, newServerCache(newFakeClock()))
+	c := dialRESP(t, addr)
+	c.do(t, "SET", "k", "v")
+	c.do(t, "GET", "k")
+	c.do(t, "GET", "missing")
+
+	info := c.do(t, "INFO")
+	for _, want := range []string{"connected_clients:1", "keyspace_hits:1", "keyspace_misses:1", "keys:1"} {
+		if !strings.Contains(info, want+"\r\n") {
+			t.Errorf("INFO lacks %q:\n%s", want, info)
+		}
+	}
+}
+
+func TestServerPipelining(t *testing.T) {
+	_, addr := startServer(t, newServerCache(newFakeClock()))
+	c := dialRESP(t, addr)
+
+	// Send every request before reading any reply, including an inline one.
+	var batch strings.Builder
+	const n = 100
+	for i := 0; i < n; i++ {
+		batch.WriteString(c.encode("SET", fmt.Sprint(i), fmt.Sprint(i*i)))
+	}
+	for i := 0; i < n; i++ {
+		batch.WriteString(c.encode("GET", fmt.Sprint(i)))
+	}
+	batch.WriteString("EXISTS 0 1 2\r\n")
+	c.send(t, batch.String())
+
+	for i := 0; i < n; i++ {
+		if got := c.reply(t); got != "OK" {
+			t.Fatalf("reply to SET %d: got %q, want OK", i, got)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if got, want := c.reply(t), fmt.Sprint(i*i); got != want {
+			t.Fatalf("reply to GET %d: got %q, want %q", i, got, want)
+		}
+	}
+	if got := c.reply(t); got != "3" {
+		t.Errorf("reply to inline EXISTS: got %q, want 3", got)
+	}
+}
+
+func TestServerMaxConns(t *testing.T) {
+	s, addr := startServer(t, newServerCache(newFakeClock()), WithMaxConns(1))
+	first := dialRESP(t, addr)
+	if got := first.do(t, "PING"); got != "PONG" {
+		t.Fatalf("PING: got %q", got)
+	}
+
+	second := dialRESP(t, addr)
+	if got := second.reply(t); got != "-ERR max number of clients reached" {
+		t.Errorf("second client: got %q, want the max clients error", got)
+	}
+
+	// Once the first client leaves, there is room again.
+	if got := first.do(t, "QUIT"); got != "OK" {
+		t.Fatalf("QUIT: got %q", got)
+	}
+	waitFor(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.conns) == 0
+	})
+	if got := dialRESP(t, addr).do(t, "PING"); got != "PONG" {
+		t.Errorf("PING after the first client left: got %q", got)
+	}
+}
+
+func TestServerProtocolError(t *testing.T) {
+	_, addr := startServer(t, newServerCache(newFakeClock()))
+	for _, req := range []string{
+		"*1\r\n+GET\r\n",
+		fmt.Sprintf("*1\r\n$%d\r\n", maxRESPBulkLen+1),
+	} {
+		c := dialRESP(t, addr)
+		c.send(t, req)
+
+		if got := c.reply(t); !strings.HasPrefix(got, "-ERR Protocol error") {
+			t.Errorf("%q: got %q, want a protocol error", req, got)
+		}
+		if _, err := c.r.ReadByte(); err == nil {
+			t.Errorf("%q: connection still open after a protocol error", req)
+		}
+	}
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	s, addr := startServer(t, newServerCache(newFakeClock()), WithIdleTimeout(20*time.Millisecond))
+	c := dialRESP(t, addr)
+	c.do(t, "PING")
+
+	if _, err := c.r.ReadByte(); err == nil {
+		t.Errorf("idle connection not closed")
+	}
+	waitFor(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.conns) == 0
+	})
+}
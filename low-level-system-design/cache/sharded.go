@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"math/bits"
+	"time"
+)
+
+// ShardedCache works with arbitrary keys and values.
+type ShardedCache = TypedShardedCache[any, any]
+
+// TypedShardedCache spreads keys across independent TypedCache shards, each
+// with its own lock, storage and eviction policy, so goroutines working on
+// different keys rarely contend. Eviction is per shard: a full shard evicts
+// its own victim even if other shards have room.
+type TypedShardedCache[K comparable, V any] struct {
+	shards []*TypedCache[K, V]
+	mask   uint64
+	hasher keyHasher
+}
+
+func NewShardedCache(shards int, newShard func() *Cache) *ShardedCache {
+	return NewTypedShardedCache(shards, newShard)
+}
+
+// NewTypedShardedCache creates a cache of shards shards, rounded up to a power
+// of two, each built by newShard.
+func NewTypedShardedCache[K comparable, V any](shards int, newShard func() *TypedCache[K, V]) *TypedShardedCache[K, V] {
+	n := 1 << bits.Len(uint(max(shards, 1)-1))
+	c := &TypedShardedCache[K, V]{
+		shards: make([]*TypedCache[K, V], n),
+		mask:   uint64(n - 1),
+		hasher: newKeyHasher(),
+	}
+	for i := range c.shards {
+		c.shards[i] = newShard()
+	}
+	return c
+}
+
+func (c *TypedShardedCache[K, V]) shard(key K) *TypedCache[K, V] {
+	return c.shards[hashKey(c.hasher, key)&c.mask]
+}
+
+func (c *TypedShardedCache[K, V]) Get(key K) (V, error) {
+	return c.shard(key).Get(key)
+}
+
+func (c *TypedShardedCache[K, V]) Put(key K, value V) error {
+	return c.shard(key).Put(key, value)
+}
+
+func (c *TypedShardedCache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) error {
+	return c.shard(key).PutWithTTL(key, value, ttl)
+}
+
+// Delete removes key from its shard. It returns ErrKeyNotFound if key isn't
+// cached.
+func (c *TypedShardedCache[K, V]) Delete(key K) error {
+	return c.shard(key).Delete(key)
+}
+
+// Len returns the number of keys held across all shards.
+func (c *TypedShardedCache[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.store.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Stats returns the sum of every shard's counters. Shards are read one at a
+// time, so a snapshot taken while the cache is in use may be slightly
+// inconsistent.
+func (c *TypedShardedCache[K, V]) Stats() CacheStats {
+	total := CacheStats{Evictions: make(map[EvictionReason]uint64)}
+	for _, s := range c.shards {
+		st := s.Stats()
+		total.Hits += st.Hits
+		total.Misses += st.Misses
+		for r, n := range st.Evictions {
+			total.Evictions[r] += n
+		}
+		total.Size += st.Size
+		total.Cost += st.Cost
+		total.LoadSuccesses = total.LoadSuccesses.merge(st.LoadSuccesses)
+		total.LoadFailures = total.LoadFailures.merge(st.LoadFailures)
+	}
+	if n := total.Hits + total.Misses; n > 0 {
+		total.HitRatio = float64(total.Hits) / float64(n)
+	}
+	return total
+}
+
+// Close closes every shard.
+func (c *TypedShardedCache[K, V]) Close() error {
+	for _, s := range c.shards {
+		s.Close()
+	}
+	return nil
+}
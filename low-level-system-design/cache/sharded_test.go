@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestShardedCache(shards, capacityPerShard int) *TypedShardedCache[int, int] {
+	return NewTypedShardedCache(shards, func() *TypedCache[int, int] {
+		return NewTypedCache[int, int](NewTypedMemoryStorage[int, int](capacityPerShard), NewTypedLRUEvictionPolicy[int]())
+	})
+}
+
+func TestShardedCacheShardCount(t *testing.T) {
+	testCases := []struct{ shards, want int }{
+		{0, 1}, {1, 1}, {2, 2}, {3, 4}, {16, 16}, {17, 32},
+	}
+	for _, tc := range testCases {
+		if got := len(newTestShardedCache(tc.shards, 1).shards); got != tc.want {
+			t.Errorf("NewTypedShardedCache(%d): got %d shards, want %d", tc.shards, got, tc.want)
+		}
+	}
+}
+
+func TestShardedCacheGetPut(t *testing.T) {
+	c := newTestShardedCache(8, 100)
+	for i := 0; i < 200; i++ {
+		if err := c.Put(i, i*10); err != nil {
+			t.Fatalf("Put(%d) failed: %v", i, err)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		if got, err := c.Get(i); err != nil || got != i*10 {
+			t.Errorf("Get(%d): got %d, %v, want %d, nil", i, got, err, i*10)
+		}
+	}
+
+	// Keys are spread over every shard.
+	for i, s := range c.shards {
+		if s.store.Len() == 0 {
+			t.Errorf("shard %d is empty", i)
+		}
+	}
+}
+
+func TestShardedCacheDelete(t *testing.T) {
+	c := newTestShardedCache(8, 100)
+	for i := 0; i < 100; i++ {
+		c.Put(i, i)
+	}
+	for i := 0; i < 100; i += 2 {
+		if err := c.Delete(i); err != nil {
+			t.Fatalf("Delete(%d) failed: %v", i, err)
+		}
+	}
+
+	if err := c.Delete(0); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Delete of a deleted key: got %v, want ErrKeyNotFound", err)
+	}
+	for i := 0; i < 100; i++ {
+		_, err := c.Get(i)
+		if deleted := i%2 == 0; deleted != errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Get(%d) after deleting even keys: got %v", i, err)
+		}
+	}
+	if got := c.Len(); got != 50 {
+		t.Errorf("Len: got %d, want 50", got)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 50 || stats.Misses != 50 || stats.Size != 50 {
+		t.Errorf("Stats: got %d hits, %d misses, size %d, want 50, 50, 50", stats.Hits, stats.Misses, stats.Size)
+	}
+	if got := stats.Evictions[EvictionDeleted]; got != 50 {
+		t.Errorf("deleted keys in Stats: got %d, want 50", got)
+	}
+}
+
+func TestShardedCacheConcurrent(t *testing.T) {
+	const (
+		numWorkers = 16
+		numOps     = 5_000
+		numKeys    = 1_000
+	)
+
+	clock := newFakeClock()
+	c := NewTypedShardedCache(8, func() *TypedCache[int, int] {
+		return NewTypedCache[int, int](
+			NewTypedMemoryStorage[int, int](64),
+			NewTypedLFUEvictionPolicy[int](),
+			WithClock(clock.Now),
+			WithJanitor(time.Millisecond),
+		)
+	})
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < numOps; i++ {
+				key := r.Intn(numKeys)
+				switch r.Intn(4) {
+				case 0:
+					if err := c.Put(key, key); err != nil {
+						t.Errorf("Put(%d) failed: %v", key, err)
+						return
+					}
+				case 1:
+					if err := c.PutWithTTL(key, key, time.Millisecond); err != nil {
+						t.Errorf("PutWithTTL(%d) failed: %v", key, err)
+						return
+					}
+					clock.Advance(time.Millisecond)
+				default:
+					if got, err := c.Get(key); err == nil && got != key {
+						t.Errorf("Get(%d): got %d", key, got)
+						return
+					}
+				}
+			}
+		}(int64(w))
+	}
+	wg.Wait()
+
+	for i, s := range c.shards {
+		s.mu.Lock()
+		size := s.store.Len()
+		s.mu.Unlock()
+		if size > 64 {
+			t.Errorf("shard %d holds %d keys, over its capacity of 64", i, size)
+		}
+	}
+}
+
+// benchmarkMixed runs a read-heavy workload from many goroutines.
+func benchmarkMixed(b *testing.B, get func(int) (int, error), put func(int, int) error) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			key := r.Intn(4 * benchCacheSize)
+			if r.Intn(10) == 0 {
+				put(key, key)
+				continue
+			}
+			if _, err := get(key); err != nil {
+				put(key, key)
+			}
+		}
+	})
+}
+
+// BenchmarkConcurrentCache compares a single-mutex TypedCache with sharded
+// caches of the same total capacity. Run with -cpu to vary parallelism.
+func BenchmarkConcurrentCache(b *testing.B) {
+	b.Run("single-mutex", func(b *testing.B) {
+		c := NewTypedCache[int, int](NewTypedMemoryStorage[int, int](benchCacheSize), NewTypedLRUEvictionPolicy[int]())
+		benchmarkMixed(b, c.Get, func(key, value int) error { return c.Put(key, value) })
+	})
+	for _, shards := range []int{4, 16, 64} {
+		b.Run(fmt.Sprintf("sharded-%d", shards), func(b *testing.B) {
+			c := newTestShardedCache(shards, benchCacheSize/shards)
+			benchmarkMixed(b, c.Get, c.Put)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// snapshotMagic starts every snapshot, followed by the format version.
+var snapshotMagic = [4]byte{'B', 'B', 'C', 'S'}
+
+const snapshotVersion uint32 = 2
+
+// ErrSnapshotFormat is returned by Restore for input that isn't a snapshot,
+// or is one in a format version it can't read.
+var ErrSnapshotFormat = errors.New("unrecognized snapshot format")
+
+// snapshot is what Snapshot writes after the header.
+type snapshot[K comparable, V any] struct {
+	// Entries are the cached keys, coldest first.
+	Entries []snapshotEntry[K, V]
+	// Ghosts are keys the eviction policy remembers having evicted.
+	Ghosts []policyKey[K]
+	// Target is a tuning the eviction policy adapts, such as ARC's target
+	// size for t1.
+	Target int
+}
+
+// snapshotEntry is one cached key as written to a snapshot. List and Count
+// are its place in the eviction policy, and a zero ExpiresAt means the key has
+// no TTL.
+type snapshotEntry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	List      int
+	Count     int
+	ExpiresAt time.Time
+	Tags      []string
+}
+
+// policyKey is where a key sits in an eviction policy: which of its lists,
+// and a count such as LFU's access count or CLOCK's referenced bit.
+type policyKey[K comparable] struct {
+	Key   K
+	List  int
+	Count int
+}
+
+// policyState is an eviction policy's state, keys coldest first.
+type policyState[K comparable] struct {
+	Keys   []policyKey[K]
+	Ghosts []policyKey[K]
+	Target int
+}
+
+// statefulPolicy is implemented by eviction policies that can export their
+// state and put it back, so a restored cache evicts in the same order as the
+// one snapshotted.
+type statefulPolicy[K comparable] interface {
+	exportState() policyState[K]
+	// importState moves each of the state's keys, which the policy already
+	// tracks, to where the state puts it, as the hottest key there so far.
+	// Ghosts the policy tracks are skipped.
+	importState(s policyState[K])
+}
+
+// stateList returns lists[i], or the nearest list if the state came from a
+// policy with a different number of lists.
+func stateList[K comparable](lists []*TypedDoublyLinkedList[K], i int) *TypedDoublyLinkedList[K] {
+	return lists[min(max(i, 0), len(lists)-1)]
+}
+
+// Snapshot writes the cache's entries and their tags to w, so a new cache can
+// start warm with Restore. Entries are encoded with the configured
+// SnapshotCodec.
+//
+// The policies in this package have their state kept: key order, access
+// counts and, for ARC and 2Q, ghost entries. Other policies have their keys
+// restored in storage order, as if each had been accessed once.
+func (c *TypedCache[K, V]) Snapshot(w io.Writer) error {
+	c.mu.Lock()
+	snap := c.snapshotLocked()
+	c.mu.Unlock()
+
+	data, err := c.config.SnapshotCodec.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+	var header bytes.Buffer
+	header.Write(snapshotMagic[:])
+	binary.Write(&header, binary.BigEndian, snapshotVersion)
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	return nil
+}
+
+func (c *TypedCache[K, V]) snapshotLocked() snapshot[K, V] {
+	var state policyState[K]
+	if sp, ok := c.policy.(statefulPolicy[K]); ok {
+		state = sp.exportState()
+	} else {
+		for _, key := range c.store.Keys() {
+			state.Keys = append(state.Keys, policyKey[K]{Key: key})
+		}
+	}
+
+	snap := snapshot[K, V]{
+		Entries: make([]snapshotEntry[K, V], 0, len(state.Keys)),
+		Ghosts:  state.Ghosts,
+		Target:  state.Target,
+	}
+	for _, k := range state.Keys {
+		val, ok := c.store.Get(k.Key)
+		if !ok || c.expiredLocked(k.Key) {
+			continue
+		}
+		e := snapshotEntry[K, V]{Key: k.Key, Value: val, List: k.List, Count: k.Count, Tags: c.tags.byKey[k.Key]}
+		if item, ok := c.expiry.lut[k.Key]; ok {
+			e.ExpiresAt = item.at
+		}
+		snap.Entries = append(snap.Entries, e)
+	}
+	return snap
+}
+
+// Restore adds the entries of a snapshot written by Snapshot, decoding them
+// with the configured SnapshotCodec. Keys keep the expiry time they had, and
+// those that have expired since are skipped. Entries are added coldest first,
+// so if the cache is smaller than the one snapshotted, the coldest are the
+// ones evicted. The eviction policy's state is then set directly, so the cost
+// of a restore depends on the number of entries, not how often they were used.
+func (c *TypedCache[K, V]) Restore(r io.Reader) error {
+	var header struct {
+		Magic   [4]byte
+		Version uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return fmt.Errorf("%w: reading header: %v", ErrSnapshotFormat, err)
+	}
+	if header.Magic != snapshotMagic {
+		return ErrSnapshotFormat
+	}
+	if header.Version != snapshotVersion {
+		return fmt.Errorf("%w: version %d, want %d", ErrSnapshotFormat, header.Version, snapshotVersion)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	var snap snapshot[K, V]
+	if err := c.config.SnapshotCodec.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("decoding snapshot: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	now := c.config.Clock()
+	var restored []policyKey[K]
+	for _, e := range snap.Entries {
+		if !e.ExpiresAt.IsZero() && !e.ExpiresAt.After(now) {
+			continue
+		}
+		if err := c.putLocked(e.Key, e.Value, 0, PutConfig{Tags: e.Tags}); err != nil {
+			return fmt.Errorf("restoring: %w", err)
+		}
+		if !e.ExpiresAt.IsZero() {
+			c.expiry.set(e.Key, e.ExpiresAt)
+		}
+		restored = append(restored, policyKey[K]{Key: e.Key, List: e.List, Count: e.Count})
+	}
+
+	sp, ok := c.policy.(statefulPolicy[K])
+	if !ok {
+		return nil
+	}
+	// Later entries may have evicted earlier ones, or not been admitted.
+	restored = slices.DeleteFunc(restored, func(k policyKey[K]) bool {
+		_, ok := c.store.Get(k.Key)
+		return !ok
+	})
+	sp.importState(policyState[K]{Keys: restored, Ghosts: snap.Ghosts, Target: snap.Target})
+	return nil
+}
@@ -0,0 +1,256 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// evictionOrder drains c's eviction policy and returns the keys in the order
+// it would have evicted them.
+func evictionOrder(c *TypedCache[string, int]) []string {
+	var keys []string
+	for range c.store.Len() {
+		keys = append(keys, c.policy.Evict())
+	}
+	return keys
+}
+
+func TestSnapshotRestorePolicyState(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy func() TypedEvictionPolicy[string]
+	}{
+		{"lru", func() TypedEvictionPolicy[string] { return NewTypedLRUEvictionPolicy[string]() }},
+		{"lfu", func() TypedEvictionPolicy[string] { return NewTypedLFUEvictionPolicy[string]() }},
+		{"arc", func() TypedEvictionPolicy[string] { return NewTypedARCEvictionPolicy[string](10) }},
+		{"2q", func() TypedEvictionPolicy[string] { return NewTypedTwoQueueEvictionPolicy[string](10) }},
+		{"clock", func() TypedEvictionPolicy[string] { return NewTypedClockEvictionPolicy[string](10) }},
+		{"w-tinylfu", func() TypedEvictionPolicy[string] { return NewTypedWTinyLFUEvictionPolicy[string](10) }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), tc.policy())
+			for i, key := range []string{"a", "b", "c", "d", "e"} {
+				src.Put(key, i)
+			}
+			for _, key := range []string{"c", "a", "c", "e", "c", "a"} {
+				src.Get(key)
+			}
+
+			var buf bytes.Buffer
+			if err := src.Snapshot(&buf); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+			dst := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), tc.policy())
+			if err := dst.Restore(&buf); err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+
+			for i, key := range []string{"a", "b", "c", "d", "e"} {
+				if got, ok := dst.store.Get(key); !ok || got != i {
+					t.Errorf("restored %s: got %d, %v, want %d", key, got, ok, i)
+				}
+			}
+			want, got := evictionOrder(src), evictionOrder(dst)
+			if !slices.Equal(got, want) {
+				t.Errorf("eviction order after restore: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSnapshotRestoreGhosts(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy func() TypedEvictionPolicy[string]
+	}{
+		{"arc", func() TypedEvictionPolicy[string] { return NewTypedARCEvictionPolicy[string](4) }},
+		{"2q", func() TypedEvictionPolicy[string] { return NewTypedTwoQueueEvictionPolicy[string](4) }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](4), tc.policy())
+			for i, key := range []string{"a", "b", "c", "d"} {
+				src.Put(key, i)
+			}
+			src.Get("a")
+			src.Get("b")
+			// Evicts keys that the policy keeps as ghosts.
+			src.Put("e", 4)
+			src.Put("f", 5)
+
+			var buf bytes.Buffer
+			if err := src.Snapshot(&buf); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+			dst := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](4), tc.policy())
+			if err := dst.Restore(&buf); err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+
+			// Bringing back an evicted key is a ghost hit in both caches: b
+			// for 2Q, c for ARC.
+			for _, c := range []*TypedCache[string, int]{src, dst} {
+				for i, key := range []string{"b", "c", "g", "h"} {
+					c.Put(key, 10+i)
+				}
+			}
+			if arc, ok := src.policy.(*TypedARCEvictionPolicy[string]); ok {
+				if got, want := dst.policy.(*TypedARCEvictionPolicy[string]).p, arc.p; got != want {
+					t.Errorf("ARC target size after restore: got %d, want %d", got, want)
+				}
+			}
+			want, got := evictionOrder(src), evictionOrder(dst)
+			if !slices.Equal(got, want) {
+				t.Errorf("eviction order after restore: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSnapshotRestoreLargeCount(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(snapshotMagic[:])
+	binary.Write(&buf, binary.BigEndian, snapshotVersion)
+	data, err := GobCodec{}.Marshal(snapshot[string, int]{Entries: []snapshotEntry[string, int]{
+		{Key: "hot", Value: 1, Count: 1 << 40},
+		{Key: "warm", Value: 2, Count: 1 << 20},
+	}})
+	if err != nil {
+		t.Fatalf("encoding snapshot: %v", err)
+	}
+	buf.Write(data)
+
+	// Counts are set, not replayed, so this doesn't take 2^40 steps.
+	c := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), NewTypedLFUEvictionPolicy[string]())
+	if err := c.Restore(&buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	c.Put("cold", 3)
+	if got, want := evictionOrder(c), []string{"cold", "warm", "hot"}; !slices.Equal(got, want) {
+		t.Errorf("eviction order after restore: got %v, want %v", got, want)
+	}
+}
+
+func TestSnapshotRestoreTTL(t *testing.T) {
+	clock := newFakeClock()
+	src := newTTLCache(10, clock)
+	src.PutWithTTL("short", 1, time.Minute)
+	src.PutWithTTL("long", 2, time.Hour)
+	src.Put("forever", 3)
+	src.PutWithTTL("expired", 4, time.Second)
+	clock.Advance(2 * time.Second)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	// Time passes between the snapshot and the restore.
+	clock.Advance(2 * time.Minute)
+	dst := newTTLCache(10, clock)
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	for _, key := range []string{"short", "expired"} {
+		if _, err := dst.Get(key); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Get(%s): got %v, want ErrKeyNotFound", key, err)
+		}
+	}
+	if got, err := dst.Get("forever"); err != nil || got != 3 {
+		t.Errorf("Get(forever): got %d, %v, want 3, nil", got, err)
+	}
+	if got, err := dst.Get("long"); err != nil || got != 2 {
+		t.Errorf("Get(long): got %d, %v, want 2, nil", got, err)
+	}
+
+	// The restored key keeps its original expiry time.
+	clock.Advance(time.Hour - 2*time.Minute - 2*time.Second)
+	if _, err := dst.Get("long"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get(long) at its expiry: got %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestSnapshotRestoreIntoSmallerCache(t *testing.T) {
+	src := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), NewTypedLRUEvictionPolicy[string]())
+	for i, key := range []string{"a", "b", "c", "d"} {
+		src.Put(key, i)
+	}
+	src.Get("a")
+
+	var buf bytes.Buffer
+	src.Snapshot(&buf)
+	dst := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](2), NewTypedLRUEvictionPolicy[string]())
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// Only the two most recently used keys fit.
+	if got, want := evictionOrder(dst), []string{"d", "a"}; !slices.Equal(got, want) {
+		t.Errorf("restored keys: got %v, want %v", got, want)
+	}
+}
+
+func TestSnapshotJSONCodec(t *testing.T) {
+	newCache := func() *TypedCache[string, int] {
+		return NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), NewTypedLFUEvictionPolicy[string](), WithSnapshotCodec(JSONCodec{}))
+	}
+	src := newCache()
+	src.Put("a", 1)
+	src.Put("b", 2)
+	src.Get("a")
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"Key":"a"`)) {
+		t.Errorf("snapshot isn't JSON: %q", buf.Bytes())
+	}
+
+	dst := newCache()
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got, want := evictionOrder(dst), []string{"b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("eviction order after restore: got %v, want %v", got, want)
+	}
+}
+
+func TestRestoreRejectsBadInput(t *testing.T) {
+	var valid bytes.Buffer
+	src := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), NewTypedLRUEvictionPolicy[string]())
+	src.Put("a", 1)
+	src.Snapshot(&valid)
+
+	newerVersion := slices.Clone(valid.Bytes())
+	newerVersion[7]++
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", append([]byte("NOPE"), valid.Bytes()[4:]...)},
+		{"newer version", newerVersion},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), NewTypedLRUEvictionPolicy[string]())
+			if err := dst.Restore(bytes.NewReader(tc.data)); !errors.Is(err, ErrSnapshotFormat) {
+				t.Errorf("Restore: got %v, want ErrSnapshotFormat", err)
+			}
+			if n := dst.store.Len(); n != 0 {
+				t.Errorf("Restore of bad input added %d keys", n)
+			}
+		})
+	}
+}
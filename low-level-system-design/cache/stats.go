@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats is a snapshot of a cache's counters.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	// HitRatio is Hits over Hits plus Misses, or zero before any lookups.
+	HitRatio float64
+	// Evictions counts keys that left the cache, by reason.
+	Evictions map[EvictionReason]uint64
+	// Size is the number of keys held.
+	Size int
+	// Cost is the total cost of the keys held, if the storage has a
+	// Cost() int64 method as MemoryStorage does, or Size otherwise.
+	Cost int64
+	// LoadSuccesses and LoadFailures time calls to a LoadingCache's loader.
+	LoadSuccesses LatencyHistogram
+	LoadFailures  LatencyHistogram
+}
+
+// LatencyHistogram counts durations into buckets.
+type LatencyHistogram struct {
+	// Bounds are the inclusive upper bounds of each bucket, in increasing
+	// order. A last bucket with no bound holds everything slower.
+	Bounds []time.Duration
+	// Counts has one count per bucket, so one more than Bounds.
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// loadLatencyBounds are the load histogram's bucket bounds.
+var loadLatencyBounds = [...]time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// cacheStats holds a cache's live counters. They are updated atomically so
+// reading them never waits on the cache's lock.
+type cacheStats struct {
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     [EvictionReplaced + 1]atomic.Uint64
+	loadSuccesses latencyHistogram
+	loadFailures  latencyHistogram
+}
+
+type latencyHistogram struct {
+	counts [len(loadLatencyBounds) + 1]atomic.Uint64
+	sum    atomic.Int64
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(loadLatencyBounds) && d > loadLatencyBounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	s := LatencyHistogram{
+		Bounds: append([]time.Duration(nil), loadLatencyBounds[:]...),
+		Counts: make([]uint64, len(h.counts)),
+		Sum:    time.Duration(h.sum.Load()),
+	}
+	for i := range h.counts {
+		s.Counts[i] = h.counts[i].Load()
+		s.Count += s.Counts[i]
+	}
+	return s
+}
+
+// merge adds the counts of o, which has the same bounds, to h. The zero
+// histogram takes o's bounds.
+func (h LatencyHistogram) merge(o LatencyHistogram) LatencyHistogram {
+	if h.Counts == nil {
+		h.Bounds = o.Bounds
+		h.Counts = make([]uint64, len(o.Counts))
+	}
+	for i, n := range o.Counts {
+		h.Counts[i] += n
+	}
+	h.Count += o.Count
+	h.Sum += o.Sum
+	return h
+}
+
+// recordLoad times a call to a loader.
+func (c *TypedCache[K, V]) recordLoad(d time.Duration, err error) {
+	if err != nil {
+		c.stats.loadFailures.observe(d)
+		return
+	}
+	c.stats.loadSuccesses.observe(d)
+}
+
+// Stats returns a snapshot of the cache's counters. Counters are read one at
+// a time, so a snapshot taken while the cache is in use may be slightly
+// inconsistent.
+func (c *TypedCache[K, V]) Stats() CacheStats {
+	s := CacheStats{
+		Hits:          c.stats.hits.Load(),
+		Misses:        c.stats.misses.Load(),
+		Evictions:     make(map[EvictionReason]uint64),
+		LoadSuccesses: c.stats.loadSuccesses.snapshot(),
+		LoadFailures:  c.stats.loadFailures.snapshot(),
+	}
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRatio = float64(s.Hits) / float64(total)
+	}
+	for r := EvictionCapacity; r <= EvictionReplaced; r++ {
+		s.Evictions[r] = c.stats.evictions[r].Load()
+	}
+
+	c.mu.Lock()
+	s.Size = c.store.Len()
+	s.Cost = int64(s.Size)
+	if coster, ok := c.store.(interface{ Cost() int64 }); ok {
+		s.Cost = coster.Cost()
+	}
+	c.mu.Unlock()
+
+	return s
+}
+
+// WritePrometheus writes s in the Prometheus text format, with every metric
+// name starting with prefix.
+func (s CacheStats) WritePrometheus(w io.Writer, prefix string) error {
+	bw := bufio.NewWriter(w)
+
+	writeMetric(bw, prefix+"_hits_total", "counter", "Lookups that found a key.")
+	fmt.Fprintf(bw, "%s_hits_total %d\n", prefix, s.Hits)
+	writeMetric(bw, prefix+"_misses_total", "counter", "Lookups that didn't find a key.")
+	fmt.Fprintf(bw, "%s_misses_total %d\n", prefix, s.Misses)
+	writeMetric(bw, prefix+"_hit_ratio", "gauge", "Hits over all lookups.")
+	fmt.Fprintf(bw, "%s_hit_ratio %g\n", prefix, s.HitRatio)
+
+	writeMetric(bw, prefix+"_evictions_total", "counter", "Keys that left the cache, by reason.")
+	for r := EvictionCapacity; r <= EvictionReplaced; r++ {
+		fmt.Fprintf(bw, "%s_evictions_total{reason=%q} %d\n", prefix, r.String(), s.Evictions[r])
+	}
+
+	writeMetric(bw, prefix+"_entries", "gauge", "Keys held.")
+	fmt.Fprintf(bw, "%s_entries %d\n", prefix, s.Size)
+	writeMetric(bw, prefix+"_cost", "gauge", "Total cost of the keys held.")
+	fmt.Fprintf(bw, "%s_cost %d\n", prefix, s.Cost)
+
+	name := prefix + "_load_duration_seconds"
+	writeMetric(bw, name, "histogram", "Time spent loading missing keys, by result.")
+	writeHistogram(bw, name, "success", s.LoadSuccesses)
+	writeHistogram(bw, name, "failure", s.LoadFailures)
+
+	return bw.Flush()
+}
+
+func writeMetric(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+// writeHistogram writes h with cumulative buckets, as Prometheus expects.
+func writeHistogram(w io.Writer, name, result string, h LatencyHistogram) {
+	var cumulative uint64
+	for i, n := range h.Counts {
+		cumulative += n
+		le := "+Inf"
+		if i < len(h.Bounds) {
+			le = strconv.FormatFloat(h.Bounds[i].Seconds(), 'g', -1, 64)
+		}
+		fmt.Fprintf(w, "%s_bucket{result=%q,le=%q} %d\n", name, result, le, cumulative)
+	}
+	fmt.Fprintf(w, "%s_sum{result=%q} %g\n", name, result, h.Sum.Seconds())
+	fmt.Fprintf(w, "%s_count{result=%q} %d\n", name, result, h.Count)
+}
+
+// PrometheusHandler serves the result of stats in the Prometheus text
+// format, with every metric name starting with prefix.
+func PrometheusHandler(prefix string, stats func() CacheStats) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		stats().WritePrometheus(w, prefix)
+	})
+}
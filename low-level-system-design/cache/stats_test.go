@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheStats(t *testing.T) {
+	clock := newFakeClock()
+	c := newTTLCache(2, clock)
+
+	c.Put("a", 1)
+	c.Put("a", 2)
+	c.Put("b", 1)
+	c.Put("c", 1)
+	c.Get("a")
+	c.Get("b")
+	c.Get("c")
+	c.Get("c")
+	c.Delete("c")
+
+	s := c.Stats()
+	if s.Hits != 3 || s.Misses != 1 {
+		t.Errorf("hits, misses: got %d, %d, want 3, 1", s.Hits, s.Misses)
+	}
+	if s.HitRatio != 0.75 {
+		t.Errorf("HitRatio: got %g, want 0.75", s.HitRatio)
+	}
+	wantEvictions := map[EvictionReason]uint64{
+		EvictionCapacity: 1,
+		EvictionExpired:  0,
+		EvictionDeleted:  1,
+		EvictionReplaced: 1,
+	}
+	for r, want := range wantEvictions {
+		if got := s.Evictions[r]; got != want {
+			t.Errorf("Evictions[%v]: got %d, want %d", r, got, want)
+		}
+	}
+	if s.Size != 1 || s.Cost != 1 {
+		t.Errorf("Size, Cost: got %d, %d, want 1, 1", s.Size, s.Cost)
+	}
+}
+
+func TestCacheStatsCost(t *testing.T) {
+	c := NewTypedCache[string, []byte](NewTypedCostMemoryStorage[string, []byte](100, byteCost), NewTypedLRUEvictionPolicy[string]())
+	c.Put("a", make([]byte, 30))
+	c.Put("b", make([]byte, 12))
+
+	if s := c.Stats(); s.Size != 2 || s.Cost != 42 {
+		t.Errorf("Size, Cost: got %d, %d, want 2, 42", s.Size, s.Cost)
+	}
+}
+
+func TestCacheStatsLoads(t *testing.T) {
+	c := newTTLCache(10, newFakeClock())
+	lc := NewTypedLoadingCache(c, func(key string) (int, error) {
+		if key == "bad" {
+			return 0, errors.New("boom")
+		}
+		return 1, nil
+	})
+	lc.Get("a")
+	lc.Get("a")
+	lc.Get("b")
+	lc.Get("bad")
+
+	s := c.Stats()
+	if s.LoadSuccesses.Count != 2 || s.LoadFailures.Count != 1 {
+		t.Errorf("load successes, failures: got %d, %d, want 2, 1", s.LoadSuccesses.Count, s.LoadFailures.Count)
+	}
+	if got, want := len(s.LoadSuccesses.Counts), len(s.LoadSuccesses.Bounds)+1; got != want {
+		t.Errorf("histogram has %d buckets, want %d", got, want)
+	}
+}
+
+func TestLatencyHistogramBuckets(t *testing.T) {
+	var h latencyHistogram
+	for _, d := range []time.Duration{0, 100 * time.Microsecond, 101 * time.Microsecond, time.Minute} {
+		h.observe(d)
+	}
+
+	s := h.snapshot()
+	last := len(s.Counts) - 1
+	if s.Counts[0] != 2 || s.Counts[1] != 1 || s.Counts[last] != 1 {
+		t.Errorf("Counts: got %v, want 2 in the first bucket, 1 in the second and 1 in the last", s.Counts)
+	}
+	if want := time.Minute + 201*time.Microsecond; s.Sum != want {
+		t.Errorf("Sum: got %v, want %v", s.Sum, want)
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	c := newTTLCache(1, newFakeClock())
+	c.Put("a", 1)
+	c.Put("b", 1)
+	c.Get("b")
+	c.Get("a")
+
+	rec := httptest.NewRecorder()
+	PrometheusHandler("test_cache", c.Stats).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		"# TYPE test_cache_hits_total counter\n",
+		"test_cache_hits_total 1\n",
+		"test_cache_misses_total 1\n",
+		"test_cache_hit_ratio 0.5\n",
+		`test_cache_evictions_total{reason="capacity"} 1` + "\n",
+		"test_cache_entries 1\n",
+		"# TYPE test_cache_load_duration_seconds histogram\n",
+		`test_cache_load_duration_seconds_bucket{result="success",le="0.0001"} 0` + "\n",
+		`test_cache_load_duration_seconds_bucket{result="failure",le="+Inf"} 0` + "\n",
+		`test_cache_load_duration_seconds_count{result="success"} 0` + "\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
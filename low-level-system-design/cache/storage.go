@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Storage and MemoryStorage hold arbitrary keys and values.
+type (
+	Storage       = TypedStorage[any, any]
+	MemoryStorage = TypedMemoryStorage[any, any]
+)
+
+type TypedStorage[K comparable, V any] interface {
+	// Add adds key or replaces its value. It returns ErrStorageFull if there
+	// isn't room, in which case the storage is unchanged.
+	Add(key K, value V) error
+	// Remove removes key and frees its space. Removing a missing key is a no-op.
+	Remove(key K)
+	Get(key K) (V, bool)
+	// Len returns the number of keys held.
+	Len() int
+	// Keys returns the keys held, in no particular order.
+	Keys() []K
+	// Clear removes every key. If it returns an error, the keys are kept.
+	Clear() error
+}
+
+// TypedMemoryStorage holds entries in a map up to a maximum total cost. By
+// default every entry costs one, so the maximum is a number of entries. A
+// maximum of zero or less means there is no limit.
+type TypedMemoryStorage[K comparable, V any] struct {
+	store   map[K]V
+	costs   map[K]int64 // nil unless cost is set.
+	cost    func(key K, value V) int64
+	maxCost int64 // No limit if zero or less.
+	size    int64 // Total cost of the entries held.
+}
+
+func NewMemoryStorage(cap int) *MemoryStorage {
+	return NewTypedMemoryStorage[any, any](cap)
+}
+
+// NewTypedMemoryStorage creates a storage holding up to cap entries, or any
+// number of them if cap is zero or less.
+func NewTypedMemoryStorage[K comparable, V any](cap int) *TypedMemoryStorage[K, V] {
+	return &TypedMemoryStorage[K, V]{
+		maxCost: int64(cap),
+		store:   make(map[K]V, max(cap, 0)),
+	}
+}
+
+func NewCostMemoryStorage(maxCost int64, cost func(key, value any) int64) *MemoryStorage {
+	return NewTypedCostMemoryStorage(maxCost, cost)
+}
+
+// NewTypedCostMemoryStorage creates a storage that holds entries until their
+// total cost, as computed by cost when they are added, would exceed maxCost.
+// For example, cost can return a value's size in bytes. A maxCost of zero or
+// less means there is no limit, though costs are still tracked. Add rejects entries
+// that cost less than one with ErrInvalidCost, since they would take no room
+// and could be added without bound.
+func NewTypedCostMemoryStorage[K comparable, V any](maxCost int64, cost func(key K, value V) int64) *TypedMemoryStorage[K, V] {
+	return &TypedMemoryStorage[K, V]{
+		maxCost: maxCost,
+		store:   make(map[K]V),
+		costs:   make(map[K]int64),
+		cost:    cost,
+	}
+}
+
+var (
+	ErrStorageFull = errors.New("storage is full")
+	// ErrInvalidCost is returned when a storage's cost function prices an
+	// entry below one.
+	ErrInvalidCost = errors.New("entry cost must be positive")
+)
+
+// ValueTooLargeError is returned when an entry costs more than a storage can
+// ever hold, so evicting other entries won't make room for it.
+type ValueTooLargeError struct {
+	Key     any
+	Cost    int64
+	MaxCost int64
+}
+
+func (e *ValueTooLargeError) Error() string {
+	return fmt.Sprintf("value for key %v costs %d, over the storage's maximum of %d", e.Key, e.Cost, e.MaxCost)
+}
+
+func (m *TypedMemoryStorage[K, V]) Add(key K, value V) error {
+	cost := int64(1)
+	if m.cost != nil {
+		cost = m.cost(key, value)
+	}
+	if cost < 1 {
+		return fmt.Errorf("%w: key %v costs %d", ErrInvalidCost, key, cost)
+	}
+	if m.maxCost > 0 && cost > m.maxCost {
+		return &ValueTooLargeError{Key: key, Cost: cost, MaxCost: m.maxCost}
+	}
+
+	// Replacing a value only needs room for the difference in cost.
+	size := m.size + cost
+	if _, ok := m.store[key]; ok {
+		size -= m.costOf(key)
+	}
+	if m.maxCost > 0 && size > m.maxCost {
+		return ErrStorageFull
+	}
+
+	m.size = size
+	m.store[key] = value
+	if m.costs != nil {
+		m.costs[key] = cost
+	}
+
+	return nil
+}
+
+func (m *TypedMemoryStorage[K, V]) Remove(key K) {
+	if _, ok := m.store[key]; !ok {
+		return
+	}
+
+	m.size -= m.costOf(key)
+	delete(m.store, key)
+	if m.costs != nil {
+		delete(m.costs, key)
+	}
+}
+
+// costOf returns the cost key was added with.
+func (m *TypedMemoryStorage[K, V]) costOf(key K) int64 {
+	if m.costs == nil {
+		return 1
+	}
+	return m.costs[key]
+}
+
+func (m *TypedMemoryStorage[K, V]) Get(key K) (V, bool) {
+	val, ok := m.store[key]
+	return val, ok
+}
+
+func (m *TypedMemoryStorage[K, V]) Len() int {
+	return len(m.store)
+}
+
+func (m *TypedMemoryStorage[K, V]) Keys() []K {
+	keys := make([]K, 0, len(m.store))
+	for key := range m.store {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (m *TypedMemoryStorage[K, V]) Clear() error {
+	clear(m.store)
+	if m.costs != nil {
+		clear(m.costs)
+	}
+	m.size = 0
+	return nil
+}
+
+// Cost returns the total cost of the entries held, which is the number of
+// entries unless the storage was created with a cost function.
+func (m *TypedMemoryStorage[K, V]) Cost() int64 {
+	return m.size
+}
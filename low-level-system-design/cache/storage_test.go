@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func byteCost(key string, value []byte) int64 { return int64(len(value)) }
+
+func TestCostMemoryStorage(t *testing.T) {
+	s := NewTypedCostMemoryStorage[string, []byte](10, byteCost)
+
+	if err := s.Add("a", make([]byte, 6)); err != nil {
+		t.Fatalf("Add(a) failed: %v", err)
+	}
+	if err := s.Add("b", make([]byte, 5)); !errors.Is(err, ErrStorageFull) {
+		t.Fatalf("Add(b) over budget: got %v, want ErrStorageFull", err)
+	}
+	if err := s.Add("b", make([]byte, 4)); err != nil {
+		t.Fatalf("Add(b) failed: %v", err)
+	}
+
+	s.Remove("a")
+	if err := s.Add("c", make([]byte, 6)); err != nil {
+		t.Errorf("Add(c) after freeing a: %v", err)
+	}
+}
+
+func TestCostMemoryStorageValueTooLarge(t *testing.T) {
+	s := NewTypedCostMemoryStorage[string, []byte](10, byteCost)
+
+	err := s.Add("big", make([]byte, 11))
+	var tooLarge *ValueTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Add: got %v, want *ValueTooLargeError", err)
+	}
+	if tooLarge.Key != "big" || tooLarge.Cost != 11 || tooLarge.MaxCost != 10 {
+		t.Errorf("Add: got %+v", tooLarge)
+	}
+	if _, ok := s.Get("big"); ok {
+		t.Error("rejected value was stored")
+	}
+}
+
+func TestCostMemoryStorageInvalidCost(t *testing.T) {
+	s := NewTypedCostMemoryStorage[string, int64](10, func(key string, value int64) int64 { return value })
+	s.Add("a", 5)
+
+	for _, cost := range []int64{0, -5} {
+		if err := s.Add("b", cost); !errors.Is(err, ErrInvalidCost) {
+			t.Errorf("Add costing %d: got %v, want ErrInvalidCost", cost, err)
+		}
+		if err := s.Add("a", cost); !errors.Is(err, ErrInvalidCost) {
+			t.Errorf("replacing with a cost of %d: got %v, want ErrInvalidCost", cost, err)
+		}
+	}
+	if _, ok := s.Get("b"); ok {
+		t.Error("rejected value was stored")
+	}
+	// A negative cost would have freed room for more than the maximum.
+	if err := s.Add("c", 6); !errors.Is(err, ErrStorageFull) {
+		t.Errorf("Add over budget: got %v, want ErrStorageFull", err)
+	}
+}
+
+func TestMemoryStorageUnbounded(t *testing.T) {
+	testCases := []struct {
+		name    string
+		storage TypedStorage[int, []byte]
+	}{
+		{"zero capacity", NewTypedMemoryStorage[int, []byte](0)},
+		{"negative capacity", NewTypedMemoryStorage[int, []byte](-1)},
+		{"zero max cost", NewTypedCostMemoryStorage(0, func(_ int, value []byte) int64 { return int64(len(value)) })},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := range 1000 {
+				if err := tc.storage.Add(i, make([]byte, 100)); err != nil {
+					t.Fatalf("Add(%d) failed: %v", i, err)
+				}
+			}
+			if n := tc.storage.Len(); n != 1000 {
+				t.Errorf("Len: got %d, want 1000", n)
+			}
+		})
+	}
+}
+
+func TestCacheEvictsUntilValueFits(t *testing.T) {
+	c := NewTypedCache[string, []byte](
+		NewTypedCostMemoryStorage[string, []byte](10, byteCost),
+		NewTypedLRUEvictionPolicy[string](),
+	)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		if err := c.Put(key, make([]byte, 2)); err != nil {
+			t.Fatalf("Put(%q) failed: %v", key, err)
+		}
+	}
+
+	// Making room for 5 bytes takes the three least recently used keys.
+	if err := c.Put("big", make([]byte, 5)); err != nil {
+		t.Fatalf("Put(big) failed: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := c.Get(key); err == nil {
+			t.Errorf("Get(%q): got nil error for evicted key", key)
+		}
+	}
+	for _, key := range []string{"d", "e", "big"} {
+		if _, err := c.Get(key); err != nil {
+			t.Errorf("Get(%q) failed: %v", key, err)
+		}
+	}
+
+	// A value over the whole budget is rejected without evicting anything.
+	var tooLarge *ValueTooLargeError
+	if err := c.Put("huge", make([]byte, 11)); !errors.As(err, &tooLarge) {
+		t.Errorf("Put(huge): got %v, want *ValueTooLargeError", err)
+	}
+	if _, err := c.Get("d"); err != nil {
+		t.Errorf("Get(d) after rejected Put: %v", err)
+	}
+}
+
+func TestMemoryStorageOverwrite(t *testing.T) {
+	s := NewTypedMemoryStorage[string, int](2)
+	s.Add("a", 1)
+	s.Add("b", 1)
+
+	// Replacing a value in a full storage needs no extra room.
+	for i := 2; i < 5; i++ {
+		if err := s.Add("a", i); err != nil {
+			t.Fatalf("overwriting a: %v", err)
+		}
+	}
+	if got, _ := s.Get("a"); got != 4 {
+		t.Errorf("Get(a): got %d, want 4", got)
+	}
+	if got := s.Len(); got != 2 {
+		t.Errorf("Len: got %d, want 2", got)
+	}
+	if err := s.Add("c", 1); !errors.Is(err, ErrStorageFull) {
+		t.Errorf("Add(c): got %v, want ErrStorageFull", err)
+	}
+}
+
+func TestMemoryStorageRemoveAndRefill(t *testing.T) {
+	s := NewTypedMemoryStorage[int, int](3)
+	for cycle := 0; cycle < 5; cycle++ {
+		for i := 0; i < 3; i++ {
+			if err := s.Add(cycle*10+i, i); err != nil {
+				t.Fatalf("cycle %d: Add(%d) failed: %v", cycle, cycle*10+i, err)
+			}
+		}
+		if err := s.Add(-1, 0); !errors.Is(err, ErrStorageFull) {
+			t.Fatalf("cycle %d: Add to full storage: got %v, want ErrStorageFull", cycle, err)
+		}
+
+		s.Remove(-1) // Missing keys don't free anything.
+		for i := 0; i < 3; i++ {
+			s.Remove(cycle*10 + i)
+			s.Remove(cycle*10 + i)
+		}
+		if got := s.Len(); got != 0 {
+			t.Fatalf("cycle %d: Len after removing everything: got %d, want 0", cycle, got)
+		}
+		if got := s.Cost(); got != 0 {
+			t.Fatalf("cycle %d: Cost after removing everything: got %d, want 0", cycle, got)
+		}
+	}
+}
+
+func TestMemoryStorageKeysAndClear(t *testing.T) {
+	s := NewTypedMemoryStorage[string, int](3)
+	s.Add("a", 1)
+	s.Add("b", 2)
+	s.Add("a", 3)
+
+	keys := s.Keys()
+	slices.Sort(keys)
+	if want := []string{"a", "b"}; !slices.Equal(keys, want) {
+		t.Errorf("Keys: got %v, want %v", keys, want)
+	}
+
+	s.Clear()
+	if got := s.Len(); got != 0 {
+		t.Errorf("Len after Clear: got %d, want 0", got)
+	}
+	if _, ok := s.Get("a"); ok {
+		t.Error("Get(a) found a key after Clear")
+	}
+	for _, key := range []string{"x", "y", "z"} {
+		if err := s.Add(key, 0); err != nil {
+			t.Errorf("Add(%q) after Clear: %v", key, err)
+		}
+	}
+}
+
+func TestCostMemoryStorageOverwrite(t *testing.T) {
+	s := NewTypedCostMemoryStorage[string, []byte](10, byteCost)
+	s.Add("a", make([]byte, 4))
+	s.Add("b", make([]byte, 4))
+
+	if err := s.Add("a", make([]byte, 6)); err != nil {
+		t.Fatalf("growing a within budget: %v", err)
+	}
+	if got := s.Cost(); got != 10 {
+		t.Errorf("Cost: got %d, want 10", got)
+	}
+
+	// A replacement that doesn't fit leaves the old value in place.
+	if err := s.Add("a", make([]byte, 7)); !errors.Is(err, ErrStorageFull) {
+		t.Fatalf("growing a over budget: got %v, want ErrStorageFull", err)
+	}
+	if got, _ := s.Get("a"); len(got) != 6 {
+		t.Errorf("Get(a): got %d bytes, want 6", len(got))
+	}
+
+	if err := s.Add("a", make([]byte, 1)); err != nil {
+		t.Fatalf("shrinking a: %v", err)
+	}
+	if got := s.Cost(); got != 5 {
+		t.Errorf("Cost after shrinking: got %d, want 5", got)
+	}
+}
+
+func TestCacheOverwriteDoesNotEvict(t *testing.T) {
+	c := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](2), NewTypedLRUEvictionPolicy[string]())
+	c.Put("a", 1)
+	c.Put("b", 1)
+	for i := 0; i < 10; i++ {
+		c.Put("a", i)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		if _, err := c.Get(key); err != nil {
+			t.Errorf("Get(%q) failed: %v", key, err)
+		}
+	}
+}
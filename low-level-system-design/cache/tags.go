@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"strings"
+	"time"
+)
+
+// PutConfig holds the options of a single Put.
+type PutConfig struct {
+	// Tags group the key with others so they can be dropped together with
+	// InvalidateTag. A key's tags are replaced on every Put.
+	// Default is no tags.
+	Tags []string
+
+	// loadTime is how long the value took to load, kept with the entry's
+	// expiry for a LoadingCache's early refresh.
+	loadTime time.Duration
+}
+
+// PutOption is used to configure a single Put.
+type PutOption func(*PutConfig)
+
+// WithTags tags the key being put.
+func WithTags(tags ...string) PutOption {
+	return func(c *PutConfig) {
+		c.Tags = append(c.Tags, tags...)
+	}
+}
+
+// withLoadTime records how long the value being put took to load.
+func withLoadTime(d time.Duration) PutOption {
+	return func(c *PutConfig) {
+		c.loadTime = d
+	}
+}
+
+// InvalidateTag removes every key tagged with tag and returns how many it
+// removed. They are reported to the OnEvict hook as EvictionDeleted.
+func (c *TypedCache[K, V]) InvalidateTag(tag string) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	keys := c.tags.keys(tag)
+	for _, key := range keys {
+		c.removeLocked(key, EvictionDeleted)
+	}
+	return len(keys)
+}
+
+// InvalidatePrefix removes every string key starting with prefix and returns
+// how many it removed. They are reported to the OnEvict hook as
+// EvictionDeleted. Keys of other types, including named string types, are
+// never matched.
+//
+// It scans every key unless the cache was created WithPrefixIndex.
+func (c *TypedCache[K, V]) InvalidatePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	var keys []K
+	if c.prefixes != nil {
+		for _, s := range c.prefixes.withPrefix(prefix) {
+			keys = append(keys, any(s).(K))
+		}
+	} else {
+		for _, key := range c.store.Keys() {
+			if s, ok := any(key).(string); ok && strings.HasPrefix(s, prefix) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	for _, key := range keys {
+		c.removeLocked(key, EvictionDeleted)
+	}
+	return len(keys)
+}
+
+// indexLocked adds key to the tag and prefix indexes after a Put.
+func (c *TypedCache[K, V]) indexLocked(key K, tags []string) {
+	c.tags.set(key, tags)
+	if c.prefixes != nil {
+		if s, ok := any(key).(string); ok {
+			c.prefixes.insert(s)
+		}
+	}
+}
+
+// unindexLocked removes key from the tag and prefix indexes once it leaves
+// the cache.
+func (c *TypedCache[K, V]) unindexLocked(key K) {
+	c.tags.remove(key)
+	if c.prefixes != nil {
+		if s, ok := any(key).(string); ok {
+			c.prefixes.remove(s)
+		}
+	}
+}
+
+// tagIndex maps tags to keys and back. Its maps are only made once a key is
+// tagged, so untagged caches pay nothing for it.
+type tagIndex[K comparable] struct {
+	byTag map[string]map[K]struct{}
+	byKey map[K][]string
+}
+
+// set replaces key's tags.
+func (t *tagIndex[K]) set(key K, tags []string) {
+	t.remove(key)
+	if len(tags) == 0 {
+		return
+	}
+	if t.byKey == nil {
+		t.byTag = make(map[string]map[K]struct{})
+		t.byKey = make(map[K][]string)
+	}
+	t.byKey[key] = tags
+	for _, tag := range tags {
+		keys, ok := t.byTag[tag]
+		if !ok {
+			keys = make(map[K]struct{})
+			t.byTag[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (t *tagIndex[K]) remove(key K) {
+	tags, ok := t.byKey[key]
+	if !ok {
+		return
+	}
+	delete(t.byKey, key)
+	for _, tag := range tags {
+		keys := t.byTag[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(t.byTag, tag)
+		}
+	}
+}
+
+// keys returns the keys tagged with tag.
+func (t *tagIndex[K]) keys(tag string) []K {
+	keys := make([]K, 0, len(t.byTag[tag]))
+	for key := range t.byTag[tag] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// prefixIndex is a radix tree of string keys, to find those with a prefix
+// without scanning them all. Each edge is labelled with a run of bytes, and a
+// node with one child that isn't itself a key is merged into it, so the tree
+// has at most twice as many nodes as keys.
+type prefixIndex struct {
+	root radixNode
+}
+
+type radixNode struct {
+	label    string // Edge from the parent; empty only for the root.
+	children []*radixNode
+	isKey    bool
+}
+
+// child returns the index of the child whose label starts with b, or -1.
+func (n *radixNode) child(b byte) int {
+	for i, c := range n.children {
+		if c.label[0] == b {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *prefixIndex) insert(key string) {
+	n := &p.root
+	for key != "" {
+		i := n.child(key[0])
+		if i < 0 {
+			n.children = append(n.children, &radixNode{label: key, isKey: true})
+			return
+		}
+		c := n.children[i]
+		common := commonPrefixLen(key, c.label)
+		if common < len(c.label) {
+			// Split the edge where key leaves it.
+			mid := &radixNode{label: c.label[:common], children: []*radixNode{c}}
+			c.label = c.label[common:]
+			n.children[i] = mid
+			c = mid
+		}
+		key = key[common:]
+		n = c
+	}
+	n.isKey = true
+}
+
+func (p *prefixIndex) remove(key string) {
+	p.root.remove(key)
+}
+
+// remove unmarks key, relative to n, and prunes the nodes left behind. It
+// reports whether key was found.
+func (n *radixNode) remove(key string) bool {
+	if key == "" {
+		found := n.isKey
+		n.isKey = false
+		return found
+	}
+	i := n.child(key[0])
+	if i < 0 {
+		return false
+	}
+	c := n.children[i]
+	if !strings.HasPrefix(key, c.label) || !c.remove(key[len(c.label):]) {
+		return false
+	}
+
+	switch {
+	case c.isKey:
+	case len(c.children) == 0:
+		n.children = append(n.children[:i], n.children[i+1:]...)
+	case len(c.children) == 1:
+		only := c.children[0]
+		only.label = c.label + only.label
+		n.children[i] = only
+	}
+	return true
+}
+
+// withPrefix returns the keys starting with prefix.
+func (p *prefixIndex) withPrefix(prefix string) []string {
+	n, path := &p.root, ""
+	for prefix != "" {
+		i := n.child(prefix[0])
+		if i < 0 {
+			return nil
+		}
+		c := n.children[i]
+		switch {
+		case strings.HasPrefix(prefix, c.label):
+			prefix = prefix[len(c.label):]
+		case strings.HasPrefix(c.label, prefix):
+			prefix = ""
+		default:
+			return nil
+		}
+		path += c.label
+		n = c
+	}
+
+	var keys []string
+	var walk func(n *radixNode, path string)
+	walk = func(n *radixNode, path string) {
+		if n.isKey {
+			keys = append(keys, path)
+		}
+		for _, c := range n.children {
+			walk(c, path+c.label)
+		}
+	}
+	walk(n, path)
+	return keys
+}
+
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
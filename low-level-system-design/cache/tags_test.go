@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	c := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), NewTypedLRUEvictionPolicy[string]())
+	events := recordEvictions(c)
+
+	c.Put("user:1", 1, WithTags("user:1"))
+	c.Put("user:1:posts", 2, WithTags("user:1", "posts"))
+	c.Put("user:2:posts", 3, WithTags("user:2", "posts"))
+	c.Put("untagged", 4)
+
+	if n := c.InvalidateTag("user:1"); n != 2 {
+		t.Errorf("InvalidateTag(user:1): removed %d keys, want 2", n)
+	}
+	for key, wantHit := range map[string]bool{"user:1": false, "user:1:posts": false, "user:2:posts": true, "untagged": true} {
+		if _, err := c.Get(key); (err == nil) != wantHit {
+			t.Errorf("Get(%s) after invalidation: got %v, want hit %v", key, err, wantHit)
+		}
+	}
+	for _, e := range events() {
+		if e.reason != EvictionDeleted {
+			t.Errorf("%s evicted as %v, want %v", e.key, e.reason, EvictionDeleted)
+		}
+	}
+
+	// The invalidated key's other tags forget it too.
+	if n := c.InvalidateTag("posts"); n != 1 {
+		t.Errorf("InvalidateTag(posts): removed %d keys, want 1", n)
+	}
+	if n := c.InvalidateTag("unknown"); n != 0 {
+		t.Errorf("InvalidateTag(unknown): removed %d keys, want 0", n)
+	}
+}
+
+func TestTagsReplacedOnPut(t *testing.T) {
+	c := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), NewTypedLRUEvictionPolicy[string]())
+	c.Put("k", 1, WithTags("old"))
+	c.Put("k", 2, WithTags("new"))
+
+	if n := c.InvalidateTag("old"); n != 0 {
+		t.Errorf("InvalidateTag(old): removed %d keys, want 0", n)
+	}
+	if n := c.InvalidateTag("new"); n != 1 {
+		t.Errorf("InvalidateTag(new): removed %d keys, want 1", n)
+	}
+
+	c.Put("k", 3, WithTags("t"))
+	c.Put("k", 4)
+	if n := c.InvalidateTag("t"); n != 0 {
+		t.Errorf("InvalidateTag after untagged Put: removed %d keys, want 0", n)
+	}
+}
+
+func TestTagIndexFollowsEvictions(t *testing.T) {
+	clock := newFakeClock()
+	c := newTTLCache(3, clock)
+	c.Put("a", 1, WithTags("t"))
+	c.Put("b", 2, WithTags("t"))
+	c.PutWithTTL("c", 3, time.Minute, WithTags("t"))
+	c.Put("d", 4, WithTags("t")) // Evicts a.
+
+	clock.Advance(time.Hour)
+	c.Get("c") // Expires c.
+	c.Delete("b")
+
+	if got := c.tags.keys("t"); !slices.Equal(got, []string{"d"}) {
+		t.Errorf("keys tagged t: got %v, want [d]", got)
+	}
+	c.Delete("d")
+	if len(c.tags.byTag) != 0 || len(c.tags.byKey) != 0 {
+		t.Errorf("tag index not empty after every key left: %v, %v", c.tags.byTag, c.tags.byKey)
+	}
+}
+
+func TestTagsSurviveSnapshot(t *testing.T) {
+	src := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), NewTypedLRUEvictionPolicy[string]())
+	src.Put("a", 1, WithTags("t"))
+	src.Put("b", 2)
+
+	var buf bytes.Buffer
+	src.Snapshot(&buf)
+	dst := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](10), NewTypedLRUEvictionPolicy[string]())
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if n := dst.InvalidateTag("t"); n != 1 {
+		t.Errorf("InvalidateTag after Restore: removed %d keys, want 1", n)
+	}
+}
+
+func TestInvalidatePrefix(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []CacheOption
+	}{
+		{"scan", nil},
+		{"index", []CacheOption{WithPrefixIndex()}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewCache(NewMemoryStorage(3), NewLRUEvictionPolicy(), tc.opts...)
+			c.Put("user:1", 1)
+			c.Put("user:10", 2)
+			c.Put(42, 3)
+			c.Put("user:2", 4)    // Evicts user:1.
+			c.Put("session:1", 5) // Evicts user:10.
+			c.Put("user:3", 6)    // Evicts 42.
+
+			if n := c.InvalidatePrefix("user:"); n != 2 {
+				t.Errorf("InvalidatePrefix(user:): removed %d keys, want 2", n)
+			}
+			if _, err := c.Get("session:1"); err != nil {
+				t.Errorf("Get(session:1): %v", err)
+			}
+			for _, key := range []any{"user:1", "user:2", "user:3"} {
+				if _, err := c.Get(key); !errors.Is(err, ErrKeyNotFound) {
+					t.Errorf("Get(%v): got %v, want ErrKeyNotFound", key, err)
+				}
+			}
+			if n := c.InvalidatePrefix(""); n != 1 {
+				t.Errorf("InvalidatePrefix of everything: removed %d keys, want 1", n)
+			}
+		})
+	}
+}
+
+func TestPrefixIndex(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var p prefixIndex
+	keys := make(map[string]bool)
+
+	// Short keys over a small alphabet share many prefixes, exercising edge
+	// splits and merges.
+	randomKey := func() string {
+		b := make([]byte, 1+r.Intn(6))
+		for i := range b {
+			b[i] = "abc"[r.Intn(3)]
+		}
+		return string(b)
+	}
+	for i := 0; i < 5000; i++ {
+		key := randomKey()
+		if r.Intn(3) == 0 {
+			p.remove(key)
+			delete(keys, key)
+		} else {
+			p.insert(key)
+			keys[key] = true
+		}
+
+		prefix := randomKey()
+		prefix = prefix[:r.Intn(min(len(prefix), 3)+1)]
+		var want []string
+		for k := range keys {
+			if strings.HasPrefix(k, prefix) {
+				want = append(want, k)
+			}
+		}
+		got := p.withPrefix(prefix)
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Fatalf("step %d: withPrefix(%q): got %v, want %v", i, prefix, got, want)
+		}
+	}
+
+	for k := range keys {
+		p.remove(k)
+	}
+	if len(p.root.children) != 0 {
+		t.Errorf("tree not empty after removing every key: %d children left", len(p.root.children))
+	}
+}
+
+func BenchmarkInvalidatePrefix(b *testing.B) {
+	const keys = 100_000
+	for _, tc := range []struct {
+		name string
+		opts []CacheOption
+	}{
+		{"scan", nil},
+		{"index", []CacheOption{WithPrefixIndex()}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			c := NewTypedCache[string, int](NewTypedMemoryStorage[string, int](keys), NewTypedLRUEvictionPolicy[string](), tc.opts...)
+			for i := 0; i < keys; i++ {
+				c.Put(fmt.Sprintf("user:%d:profile", i), i)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := fmt.Sprintf("user:%d:profile", i%keys)
+				c.InvalidatePrefix(key)
+				c.Put(key, i)
+			}
+		})
+	}
+}
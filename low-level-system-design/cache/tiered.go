@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// TieredCache works with arbitrary keys and values.
+type TieredCache = TypedTieredCache[any, any]
+
+// TypedTieredCache keeps hot entries in a fast cache and demotes entries it
+// evicts for capacity to a larger, slower one, typically backed by
+// DiskStorage. A hit in the slow tier promotes the entry back.
+//
+// A key lives in at most one tier. TTLs are not carried over to the slow
+// tier, and entries that expire in the fast tier are not demoted.
+type TypedTieredCache[K comparable, V any] struct {
+	mu   sync.Mutex
+	hot  *TypedCache[K, V]
+	cold *TypedCache[K, V]
+}
+
+func NewTieredCache(hot, cold *Cache) *TieredCache {
+	return NewTypedTieredCache(hot, cold)
+}
+
+// NewTypedTieredCache creates a tiered cache with hot in front of cold. It
+// takes over hot's OnEvict hook to demote entries, so hot must not use
+// WithAsyncEvictions.
+func NewTypedTieredCache[K comparable, V any](hot, cold *TypedCache[K, V]) *TypedTieredCache[K, V] {
+	t := &TypedTieredCache[K, V]{hot: hot, cold: cold}
+	hot.OnEvict(t.demote)
+	return t
+}
+
+// demote moves an entry evicted from the fast tier to the slow one. It runs
+// while the caller holds t.mu, since the hook is called before the fast
+// tier's Put returns. A failed demotion drops the entry.
+func (t *TypedTieredCache[K, V]) demote(key K, value V, reason EvictionReason) {
+	if reason == EvictionCapacity {
+		t.cold.Put(key, value)
+	}
+}
+
+func (t *TypedTieredCache[K, V]) Get(key K) (V, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if val, err := t.hot.Get(key); err == nil {
+		return val, nil
+	}
+	val, err := t.cold.Get(key)
+	if err != nil {
+		return val, err
+	}
+
+	// Promote the entry, which may demote another in its place. Deleting it
+	// first leaves room in the slow tier for that. The fast tier's admission
+	// policy may turn it away, so it is written back unless the fast tier
+	// holds it.
+	t.cold.Delete(key)
+	err = t.hot.Put(key, val)
+	if _, ok := t.hot.peek(key); err != nil || !ok {
+		t.cold.Put(key, val)
+	}
+	return val, nil
+}
+
+func (t *TypedTieredCache[K, V]) Put(key K, value V) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cold.Delete(key)
+	return t.hot.Put(key, value)
+}
+
+// Delete removes key from both tiers. It returns ErrKeyNotFound if neither
+// had it.
+func (t *TypedTieredCache[K, V]) Delete(key K) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hotErr := t.hot.Delete(key)
+	coldErr := t.cold.Delete(key)
+	if errors.Is(hotErr, ErrKeyNotFound) && errors.Is(coldErr, ErrKeyNotFound) {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// Hot returns the fast tier.
+func (t *TypedTieredCache[K, V]) Hot() *TypedCache[K, V] {
+	return t.hot
+}
+
+// Cold returns the slow tier.
+func (t *TypedTieredCache[K, V]) Cold() *TypedCache[K, V] {
+	return t.cold
+}
+
+// Close closes both tiers. It does not close the slow tier's storage.
+func (t *TypedTieredCache[K, V]) Close() error {
+	t.hot.Close()
+	return t.cold.Close()
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func newTestTieredCache(t *testing.T, hotCap, coldCap int) *TypedTieredCache[string, []byte] {
+	t.Helper()
+	d := openTestDiskStorage(t, filepath.Join(t.TempDir(), "log"), coldCap)
+	hot := NewTypedCache[string, []byte](NewTypedMemoryStorage[string, []byte](hotCap), NewTypedLRUEvictionPolicy[string]())
+	cold := NewTypedCache[string, []byte](d, NewTypedLRUEvictionPolicy[string]())
+	return NewTypedTieredCache(hot, cold)
+}
+
+func TestTieredCacheDemotesAndPromotes(t *testing.T) {
+	c := newTestTieredCache(t, 2, 10)
+	for _, key := range []string{"a", "b", "c"} {
+		c.Put(key, []byte(key))
+	}
+
+	// a was evicted from the fast tier and demoted.
+	if _, err := c.Hot().Get("a"); err == nil {
+		t.Error("a still in the fast tier")
+	}
+	if _, err := c.Cold().Get("a"); err != nil {
+		t.Errorf("a not demoted: %v", err)
+	}
+
+	// A hit in the slow tier promotes a and demotes b, the fast tier's LRU key.
+	if got, err := c.Get("a"); err != nil || string(got) != "a" {
+		t.Fatalf("Get(a): got %q, %v, want a, nil", got, err)
+	}
+	if _, err := c.Hot().Get("a"); err != nil {
+		t.Error("a not promoted")
+	}
+	if _, err := c.Cold().Get("a"); err == nil {
+		t.Error("a still in the slow tier after promotion")
+	}
+	if _, err := c.Cold().Get("b"); err != nil {
+		t.Errorf("b not demoted: %v", err)
+	}
+}
+
+// rejectAll is an admission policy that never lets a new key in.
+type rejectAll struct{}
+
+func (rejectAll) Record(string)          {}
+func (rejectAll) Admit(_, _ string) bool { return false }
+
+func TestTieredCachePromotionRejected(t *testing.T) {
+	c := newTestTieredCache(t, 1, 10)
+	c.Put("a", []byte("a"))
+	c.Put("b", []byte("b"))
+	c.Hot().SetAdmissionPolicy(rejectAll{})
+
+	// The fast tier won't take a, so it has to stay in the slow one.
+	for range 2 {
+		if got, err := c.Get("a"); err != nil || string(got) != "a" {
+			t.Fatalf("Get(a): got %q, %v, want a, nil", got, err)
+		}
+	}
+	if _, err := c.Cold().Get("a"); err != nil {
+		t.Errorf("a dropped from the slow tier: %v", err)
+	}
+	if _, err := c.Hot().Get("b"); err != nil {
+		t.Errorf("b evicted from the fast tier: %v", err)
+	}
+}
+
+func TestTieredCacheSlowTierEviction(t *testing.T) {
+	c := newTestTieredCache(t, 1, 2)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.Put(key, []byte(key))
+	}
+
+	// d is hot, b and c are cold and a fell out of both tiers.
+	for _, key := range []string{"b", "c", "d"} {
+		if _, err := c.Get(key); err != nil {
+			t.Errorf("Get(%q) failed: %v", key, err)
+		}
+	}
+	if _, err := c.Get("a"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get(a): got %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestTieredCachePutAndDelete(t *testing.T) {
+	c := newTestTieredCache(t, 1, 10)
+	c.Put("a", []byte("1"))
+	c.Put("b", []byte("1"))
+
+	// Writing a demoted key replaces the stale copy in the slow tier.
+	c.Put("a", []byte("2"))
+	if _, err := c.Cold().Get("a"); err == nil {
+		t.Error("stale a left in the slow tier")
+	}
+	if got, _ := c.Get("a"); string(got) != "2" {
+		t.Errorf("Get(a): got %q, want 2", got)
+	}
+
+	if err := c.Delete("b"); err != nil {
+		t.Errorf("Delete(b) failed: %v", err)
+	}
+	if _, err := c.Get("b"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get(b) after Delete: got %v, want ErrKeyNotFound", err)
+	}
+	if err := c.Delete("b"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Delete(b) twice: got %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+
+	bb "github.com/ahrav/BlueprintBazaar"
+)
+
+// TinyLFU admits arbitrary keys.
+type TinyLFU = TypedTinyLFU[any]
+
+// TypedTinyLFU is an admission policy that lets a new key into a full cache only
+// if it has been accessed more often than the key it would displace.
+//
+// Access counts are estimated with a count-min sketch that is halved every
+// SampleSize accesses, so keys that were popular long ago fade out. With the
+// doorkeeper enabled, a key's first access only marks it in a bloom filter and
+// the sketch counts it from the second access on, which keeps one-hit wonders
+// from crowding the sketch.
+//
+// As an admission policy, a new key competes with the eviction policy's victim
+// from its first Put, so a burst of keys that are about to become popular is
+// rejected until their counts catch up. WTinyLFUEvictionPolicy puts a window in
+// front of the filter to give them that time.
+type TypedTinyLFU[K comparable] struct {
+	sketch     *countMinSketch
+	doorkeeper *bb.BasicBloomFilter // nil if disabled.
+	config     TinyLFUConfig
+	additions  int
+	hasher     keyHasher
+	// buf holds the key's hash for the doorkeeper, which takes bytes.
+	buf [8]byte
+	// sum hashes buf for the doorkeeper.
+	sum *identityHash
+}
+
+// TinyLFUConfig is needed to create a new TinyLFU admission policy.
+type TinyLFUConfig struct {
+	// SampleSize is the number of recorded accesses after which all counts
+	// are halved.
+	// Default is 10 times the cache capacity.
+	SampleSize int
+	// Doorkeeper enables the bloom filter in front of the sketch.
+	// Default is false.
+	Doorkeeper bool
+}
+
+// TinyLFUOption is used to configure a new TinyLFU admission policy.
+type TinyLFUOption func(*TinyLFUConfig)
+
+// WithSampleSize sets the number of recorded accesses after which all counts are halved.
+func WithSampleSize(n int) TinyLFUOption {
+	return func(c *TinyLFUConfig) {
+		c.SampleSize = n
+	}
+}
+
+// WithDoorkeeper enables the bloom filter in front of the sketch.
+func WithDoorkeeper() TinyLFUOption {
+	return func(c *TinyLFUConfig) {
+		c.Doorkeeper = true
+	}
+}
+
+func NewTinyLFU(capacity int, opts ...TinyLFUOption) *TinyLFU {
+	return NewTypedTinyLFU[any](capacity, opts...)
+}
+
+// NewTypedTinyLFU creates a TinyLFU admission policy for a cache holding capacity keys.
+func NewTypedTinyLFU[K comparable](capacity int, opts ...TinyLFUOption) *TypedTinyLFU[K] {
+	const sampleFactor = 10
+	c := TinyLFUConfig{SampleSize: sampleFactor * capacity}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	t := &TypedTinyLFU[K]{
+		sketch: newCountMinSketch(sketchWidthFactor * capacity),
+		config: c,
+		hasher: newKeyHasher(),
+		sum:    new(identityHash),
+	}
+	t.resetDoorkeeper()
+	return t
+}
+
+// resetDoorkeeper replaces the doorkeeper, if enabled, with an empty one.
+func (t *TypedTinyLFU[K]) resetDoorkeeper() {
+	if !t.config.Doorkeeper {
+		return
+	}
+	t.doorkeeper = bb.NewBasicBloomFilter(
+		bb.WithCapacity(uint64(max(t.config.SampleSize, 1))),
+		bb.WithHasher(identityHasher{t.sum}),
+	)
+}
+
+// seen reports whether the doorkeeper has seen the key with hash h.
+func (t *TypedTinyLFU[K]) seen(h uint64) bool {
+	binary.LittleEndian.PutUint64(t.buf[:], h)
+	ok, _ := t.doorkeeper.Test(t.buf[:])
+	return ok
+}
+
+func (t *TypedTinyLFU[K]) Record(key K) {
+	h := hashKey(t.hasher, key)
+	if t.doorkeeper != nil && !t.seen(h) {
+		t.doorkeeper.Add(t.buf[:])
+		return
+	}
+
+	t.sketch.increment(h)
+	t.additions++
+	if t.additions >= t.config.SampleSize {
+		t.age()
+	}
+}
+
+func (t *TypedTinyLFU[K]) Admit(candidate, victim K) bool {
+	return t.Estimate(candidate) > t.Estimate(victim)
+}
+
+// Estimate returns the approximate number of recent accesses to key.
+func (t *TypedTinyLFU[K]) Estimate(key K) int {
+	h := hashKey(t.hasher, key)
+	n := int(t.sketch.estimate(h))
+	if t.doorkeeper != nil && t.seen(h) {
+		n++
+	}
+	return n
+}
+
+// age halves every count and forgets which keys the doorkeeper has seen.
+func (t *TypedTinyLFU[K]) age() {
+	t.sketch.halve()
+	t.additions /= 2
+	t.resetDoorkeeper()
+}
+
+// identityHasher lets the doorkeeper use the key's hash, which TinyLFU has
+// already computed, rather than hash the key again. It hands out the same
+// identityHash every time, which is safe as TinyLFU isn't used concurrently.
+type identityHasher struct {
+	h *identityHash
+}
+
+func (i identityHasher) HashFn() hash.Hash64 { return i.h }
+
+func (identityHasher) Name() string { return "identity" }
+
+// identityHash sums to the last 8 bytes written to it, read as a little-endian
+// uint64.
+type identityHash struct {
+	sum uint64
+}
+
+func (h *identityHash) Write(p []byte) (int, error) {
+	if len(p) >= 8 {
+		h.sum = binary.LittleEndian.Uint64(p[len(p)-8:])
+	}
+	return len(p), nil
+}
+
+func (h *identityHash) Sum(b []byte) []byte { return binary.BigEndian.AppendUint64(b, h.sum) }
+func (h *identityHash) Sum64() uint64       { return h.sum }
+func (h *identityHash) Reset()              { h.sum = 0 }
+func (h *identityHash) Size() int           { return 8 }
+func (h *identityHash) BlockSize() int      { return 8 }
+
+// countMinSketch estimates access counts in fixed space. Every key maps to one
+// counter per row and its estimate is the smallest of them, so collisions can
+// only inflate an estimate. Counters saturate at maxCount.
+type countMinSketch struct {
+	rows [sketchDepth][]uint8
+	mask uint64
+}
+
+const (
+	sketchDepth       = 4
+	sketchWidthFactor = 4 // Counters per row for each key the cache holds.
+	maxCount          = 15
+)
+
+func newCountMinSketch(width int) *countMinSketch {
+	// Round the width up to a power of two so indexing is a mask.
+	w := uint64(1) << bits.Len64(uint64(max(width, 64)-1))
+	s := &countMinSketch{mask: w - 1}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+// index derives the counter for row i from a single hash by double hashing.
+func (s *countMinSketch) index(h uint64, i int) uint64 {
+	lo, hi := h, h>>32|1
+	return (lo + uint64(i)*hi) & s.mask
+}
+
+func (s *countMinSketch) increment(h uint64) {
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < maxCount {
+			*c++
+		}
+	}
+}
+
+func (s *countMinSketch) estimate(h uint64) uint8 {
+	n := uint8(maxCount)
+	for i := range s.rows {
+		n = min(n, s.rows[i][s.index(h, i)])
+	}
+	return n
+}
+
+func (s *countMinSketch) halve() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+var (
+	_ AdmissionPolicy                = (*TinyLFU)(nil)
+	_ victimPeeker[int]              = (*TypedLRUEvictionPolicy[int])(nil)
+	_ victimPeeker[int]              = (*TypedLFUEvictionPolicy[int])(nil)
+	_ victimPeeker[int]              = (*TypedARCEvictionPolicy[int])(nil)
+	_ victimPeeker[int]              = (*TypedTwoQueueEvictionPolicy[int])(nil)
+	_ victimPeeker[int]              = (*TypedWTinyLFUEvictionPolicy[int])(nil)
+	_ EvictionPolicy                 = (*WTinyLFUEvictionPolicy)(nil)
+	_ TypedAdmissionPolicy[[2]int64] = (*TypedTinyLFU[[2]int64])(nil)
+)
+
+func TestCountMinSketch(t *testing.T) {
+	s := newCountMinSketch(64)
+	counts := map[uint64]int{0x1234_5678_9abc: 1, 0xfeed_beef_0042: 5, 0x0bad_cafe_1337: 20}
+	for h, n := range counts {
+		for i := 0; i < n; i++ {
+			s.increment(h)
+		}
+	}
+
+	for h, n := range counts {
+		got := int(s.estimate(h))
+		want := min(n, maxCount)
+		// Collisions can only overestimate.
+		if got < want {
+			t.Errorf("estimate(%d): got %d, want at least %d", h, got, want)
+		}
+	}
+
+	s.halve()
+	if got := s.estimate(0x0bad_cafe_1337); got != maxCount/2 {
+		t.Errorf("estimate after halving: got %d, want %d", got, maxCount/2)
+	}
+}
+
+func TestTinyLFUAdmit(t *testing.T) {
+	for _, doorkeeper := range []bool{false, true} {
+		t.Run(fmt.Sprintf("doorkeeper=%v", doorkeeper), func(t *testing.T) {
+			var opts []TinyLFUOption
+			if doorkeeper {
+				opts = append(opts, WithDoorkeeper())
+			}
+			lfu := NewTypedTinyLFU[string](100, opts...)
+
+			for i := 0; i < 5; i++ {
+				lfu.Record("hot")
+			}
+			lfu.Record("warm")
+			lfu.Record("warm")
+
+			if !lfu.Admit("hot", "warm") {
+				t.Error("Admit(hot, warm): got false, want true")
+			}
+			if lfu.Admit("warm", "hot") {
+				t.Error("Admit(warm, hot): got true, want false")
+			}
+			if lfu.Admit("cold", "warm") {
+				t.Error("Admit(cold, warm): got true, want false")
+			}
+			// Ties go to the resident key.
+			if lfu.Admit("warm", "warm") {
+				t.Error("Admit(warm, warm): got true, want false")
+			}
+		})
+	}
+}
+
+func TestTinyLFUDoorkeeper(t *testing.T) {
+	lfu := NewTypedTinyLFU[string](100, WithDoorkeeper())
+
+	lfu.Record("once")
+	if got := lfu.sketch.estimate(hashKey(lfu.hasher, "once")); got != 0 {
+		t.Errorf("sketch count after first access: got %d, want 0", got)
+	}
+	if got := lfu.Estimate("once"); got != 1 {
+		t.Errorf("Estimate after first access: got %d, want 1", got)
+	}
+
+	lfu.Record("once")
+	if got := lfu.Estimate("once"); got != 2 {
+		t.Errorf("Estimate after second access: got %d, want 2", got)
+	}
+}
+
+func TestTinyLFURecordAllocs(t *testing.T) {
+	lfu := NewTypedTinyLFU[string](100, WithDoorkeeper())
+	if n := testing.AllocsPerRun(100, func() { lfu.Record("key") }); n != 0 {
+		t.Errorf("Record allocated %v times, want 0", n)
+	}
+}
+
+func TestTinyLFUAging(t *testing.T) {
+	const sampleSize = 20
+	lfu := NewTypedTinyLFU[int](100, WithSampleSize(sampleSize))
+
+	for i := 0; i < 8; i++ {
+		lfu.Record(1)
+	}
+	if got := lfu.Estimate(1); got != 8 {
+		t.Fatalf("Estimate before aging: got %d, want 8", got)
+	}
+
+	// Filling the sample with other keys halves the count.
+	for i := 0; i < sampleSize-8; i++ {
+		lfu.Record(1000 + i)
+	}
+	if got := lfu.Estimate(1); got != 4 {
+		t.Errorf("Estimate after aging: got %d, want 4", got)
+	}
+}
+
+func TestCacheAdmissionRejectsOneHitWonders(t *testing.T) {
+	const capacity = 50
+
+	// hotMissRatio reads a hot set that fills the cache, interleaved with a scan
+	// of keys that are only seen once, and returns how often hot reads missed.
+	hotMissRatio := func(admission TypedAdmissionPolicy[int]) float64 {
+		c := NewTypedCache[int, int](NewTypedMemoryStorage[int, int](capacity), NewTypedLRUEvictionPolicy[int]())
+		if admission != nil {
+			c.SetAdmissionPolicy(admission)
+		}
+		read := func(key int) bool {
+			if _, err := c.Get(key); err == nil {
+				return true
+			}
+			if err := c.Put(key, key); err != nil {
+				t.Fatalf("Put(%d) failed: %v", key, err)
+			}
+			return false
+		}
+
+		for round := 0; round < 3; round++ {
+			for key := 0; key < capacity; key++ {
+				read(key)
+			}
+		}
+
+		var hotReads, hotMisses int
+		for key := 1000; key < 3000; key++ {
+			for i := 0; i < 2; i++ {
+				hotReads++
+				if !read((2*key + i) % capacity) {
+					hotMisses++
+				}
+			}
+			read(key)
+		}
+		return float64(hotMisses) / float64(hotReads)
+	}
+
+	without := hotMissRatio(nil)
+	with := hotMissRatio(NewTypedTinyLFU[int](capacity, WithDoorkeeper()))
+	t.Logf("hot miss ratio: %.3f without admission, %.3f with TinyLFU", without, with)
+	if with > 0.15 {
+		t.Errorf("hot miss ratio with TinyLFU: got %.3f, want at most 0.15", with)
+	}
+	if with >= without {
+		t.Errorf("TinyLFU hot miss ratio %.3f not below plain LRU %.3f", with, without)
+	}
+}
+
+func TestCacheAdmissionAdmitsRepeatedKey(t *testing.T) {
+	const capacity = 10
+	c := NewTypedCache[int, int](NewTypedMemoryStorage[int, int](capacity), NewTypedLRUEvictionPolicy[int]())
+	c.SetAdmissionPolicy(NewTypedTinyLFU[int](capacity))
+	for key := 0; key < capacity; key++ {
+		if err := c.Put(key, key); err != nil {
+			t.Fatalf("Put(%d) failed: %v", key, err)
+		}
+	}
+
+	// A key that keeps coming back eventually beats the coldest resident key.
+	for i := 0; i < 10; i++ {
+		if _, err := c.Get(500); err == nil {
+			return
+		}
+		if err := c.Put(500, 500); err != nil {
+			t.Fatalf("Put(500) failed: %v", err)
+		}
+	}
+	t.Error("frequently requested key never admitted")
+}
+
+func TestCacheAdmissionOverwriteWithLargerCost(t *testing.T) {
+	c := NewTypedCache[string, []byte](NewTypedCostMemoryStorage[string, []byte](10, byteCost), NewTypedLRUEvictionPolicy[string]())
+	c.SetAdmissionPolicy(NewTypedTinyLFU[string](10))
+	c.Put("b", make([]byte, 4))
+	c.Put("c", make([]byte, 4))
+	for range 10 {
+		c.Get("b")
+		c.Get("c")
+	}
+	c.Put("a", make([]byte, 2))
+
+	// The update has to evict the hot key b, which the cold key a wouldn't be
+	// admitted over, but a is already cached and must not keep its old value.
+	if err := c.Put("a", make([]byte, 6)); err != nil {
+		t.Fatalf("Put(a) failed: %v", err)
+	}
+	if got, err := c.Get("a"); err != nil || len(got) != 6 {
+		t.Errorf("Get(a): got %d bytes, %v, want 6 bytes, nil", len(got), err)
+	}
+	if _, err := c.Get("b"); err == nil {
+		t.Error("b still cached after a grew")
+	}
+}
+
+func TestWTinyLFUEvictionPolicyWindow(t *testing.T) {
+	w := NewTypedWTinyLFUEvictionPolicy[int](100) // window 1, main 99
+	for key := range 100 {
+		w.ItemAccessed(key)
+		w.ItemAccessed(key)
+	}
+
+	// A new key gets into the window even though it has been seen less
+	// than every resident key.
+	w.ItemAccessed(500)
+	if got := w.Evict(); got == 500 {
+		t.Fatal("Evict took the new key instead of the window's old one")
+	}
+	if w.lut[500].list != w.window {
+		t.Fatal("new key not in the window")
+	}
+
+	// Pushed out of the window, it loses to the main segment's victim.
+	w.ItemAccessed(501)
+	if got := w.Evict(); got != 500 {
+		t.Errorf("Evict: got %d, want the one-hit key 500", got)
+	}
+
+	// A key seen more often than the victim wins its place in probation.
+	for range 5 {
+		w.ItemAccessed(501)
+	}
+	w.ItemAccessed(502)
+	victim, _ := w.Victim()
+	if got := w.Evict(); got == 501 || got != victim {
+		t.Errorf("Evict: got %d, Victim said %d, want a main key other than 501", got, victim)
+	}
+	if w.lut[501].list != w.probation {
+		t.Error("frequent key not moved to probation")
+	}
+}
+
+func TestWTinyLFUEvictionPolicyProtected(t *testing.T) {
+	w := NewTypedWTinyLFUEvictionPolicy[int](10) // window 1, main 9, protected 7
+	for key := range 10 {
+		w.ItemAccessed(key)
+	}
+	w.Victim() // Moves keys over the window's target into probation.
+	for key := range 9 {
+		w.ItemAccessed(key)
+	}
+	if got, want := w.protected.size, w.protectedSize; got != want {
+		t.Fatalf("protected size: got %d, want %d", got, want)
+	}
+	// The least recently promoted keys were demoted back to probation.
+	for _, key := range []int{0, 1} {
+		if w.lut[key].list != w.probation {
+			t.Errorf("key %d not demoted to probation", key)
+		}
+	}
+}
+
+func TestWTinyLFUEvictionPolicyVictimMatchesEvict(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	w := NewTypedWTinyLFUEvictionPolicy[int](16)
+	for i := 0; i < 10_000; i++ {
+		switch key := r.Intn(32); r.Intn(4) {
+		case 0:
+			victim, ok := w.Victim()
+			if got := w.Evict(); ok && got != victim {
+				t.Fatalf("step %d: Evict got %d, Victim said %d", i, got, victim)
+			}
+		case 1:
+			w.Remove(key)
+		default:
+			w.ItemAccessed(key)
+		}
+	}
+	if n := w.window.size + w.probation.size + w.protected.size; n != len(w.lut) {
+		t.Errorf("lists hold %d keys, tracking %d", n, len(w.lut))
+	}
+}
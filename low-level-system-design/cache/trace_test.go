@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+var (
+	_ EvictionPolicy = (*ARCEvictionPolicy)(nil)
+	_ EvictionPolicy = (*TwoQueueEvictionPolicy)(nil)
+)
+
+// tracePolicy names a policy constructor for trace replays.
+type tracePolicy struct {
+	name string
+	new  func(capacity int) TypedEvictionPolicy[int]
+}
+
+var tracePolicies = []tracePolicy{
+	{"lru", func(int) TypedEvictionPolicy[int] { return NewTypedLRUEvictionPolicy[int]() }},
+	{"lfu", func(int) TypedEvictionPolicy[int] { return NewTypedLFUEvictionPolicy[int]() }},
+	{"arc", func(c int) TypedEvictionPolicy[int] { return NewTypedARCEvictionPolicy[int](c) }},
+	{"2q", func(c int) TypedEvictionPolicy[int] { return NewTypedTwoQueueEvictionPolicy[int](c) }},
+	{"clock", func(c int) TypedEvictionPolicy[int] { return NewTypedClockEvictionPolicy[int](c) }},
+	{"w-tinylfu", func(c int) TypedEvictionPolicy[int] { return NewTypedWTinyLFUEvictionPolicy[int](c) }},
+	{"sampled-lru", func(int) TypedEvictionPolicy[int] {
+		return NewTypedSampledLRUEvictionPolicy[int](5, WithSampledRand(rand.New(rand.NewSource(1))))
+	}},
+}
+
+// replayTrace runs a cache-aside workload over keys and returns the hit ratio:
+// every key is read, and misses are filled with a Put.
+func replayTrace(t testing.TB, capacity int, policy TypedEvictionPolicy[int], keys []int) float64 {
+	t.Helper()
+
+	c := NewTypedCache[int, int](NewTypedMemoryStorage[int, int](capacity), policy)
+	hits := 0
+	for _, key := range keys {
+		if _, err := c.Get(key); err == nil {
+			hits++
+			continue
+		}
+		if err := c.Put(key, key); err != nil {
+			t.Fatalf("Put(%d) failed: %v", key, err)
+		}
+	}
+	return float64(hits) / float64(len(keys))
+}
+
+// hotSetWithScans repeatedly reads a hot set and periodically interleaves a
+// scan over keys that are never read again.
+func hotSetWithScans(r *rand.Rand, hot, scan, rounds int) []int {
+	var keys []int
+	next := hot
+	for i := 0; i < rounds; i++ {
+		for j := 0; j < 4*hot; j++ {
+			keys = append(keys, r.Intn(hot))
+		}
+		for j := 0; j < scan; j++ {
+			keys = append(keys, next)
+			next++
+		}
+	}
+	return keys
+}
+
+func zipfTrace(r *rand.Rand, universe uint64, n int) []int {
+	z := rand.NewZipf(r, 1.1, 1, universe-1)
+	keys := make([]int, n)
+	for i := range keys {
+		keys[i] = int(z.Uint64())
+	}
+	return keys
+}
+
+// loopTrace cycles over a working set slightly larger than the cache, which is
+// LRU's worst case.
+func loopTrace(size, rounds int) []int {
+	var keys []int
+	for i := 0; i < rounds; i++ {
+		for k := 0; k < size; k++ {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestTraceHitRatios(t *testing.T) {
+	const capacity = 100
+
+	traces := []struct {
+		name string
+		keys []int
+		// scanResistant lists policies expected to beat LRU on this trace.
+		scanResistant []string
+	}{
+		{"hot set with scans", hotSetWithScans(rand.New(rand.NewSource(1)), 60, 100, 30), []string{"lfu", "arc", "2q", "w-tinylfu"}},
+		{"zipf", zipfTrace(rand.New(rand.NewSource(2)), 1000, 20_000), nil},
+		// ARC keeps no ghosts while every key is in t1, so like LRU it misses on
+		// every access here; 2Q's a1out still catches the loop.
+		{"loop", loopTrace(capacity+10, 20), []string{"2q"}},
+	}
+
+	for _, trace := range traces {
+		t.Run(trace.name, func(t *testing.T) {
+			ratios := make(map[string]float64, len(tracePolicies))
+			for _, p := range tracePolicies {
+				ratios[p.name] = replayTrace(t, capacity, p.new(capacity), trace.keys)
+				t.Logf("%-11s hit ratio %.3f", p.name, ratios[p.name])
+			}
+			for _, name := range trace.scanResistant {
+				if ratios[name] <= ratios["lru"] {
+					t.Errorf("%s hit ratio %.3f not above lru %.3f", name, ratios[name], ratios["lru"])
+				}
+			}
+		})
+	}
+}
+
+func TestARCEvictionPolicyAdapts(t *testing.T) {
+	const capacity = 4
+	arc := NewTypedARCEvictionPolicy[int](capacity)
+	c := NewTypedCache[int, int](NewTypedMemoryStorage[int, int](capacity), arc)
+
+	put := func(keys ...int) {
+		for _, key := range keys {
+			if err := c.Put(key, key); err != nil {
+				t.Fatalf("Put(%d) failed: %v", key, err)
+			}
+		}
+	}
+	get := func(keys ...int) {
+		for _, key := range keys {
+			if _, err := c.Get(key); err != nil {
+				t.Fatalf("Get(%d) failed: %v", key, err)
+			}
+		}
+	}
+
+	// Keys 1 and 2 are seen twice and move to t2; the scan only churns t1.
+	put(1, 2)
+	get(1, 2)
+	put(10, 11, 12)
+	get(1, 2)
+	if arc.p != 0 {
+		t.Fatalf("p after scan: got %d, want 0", arc.p)
+	}
+
+	// Re-reading a key evicted from t1 is a b1 ghost hit, which grows t1's target.
+	if _, err := c.Get(10); err == nil {
+		t.Fatal("Get(10): got hit for evicted key")
+	}
+	put(10)
+	if arc.p == 0 {
+		t.Errorf("p after b1 ghost hit: got 0, want > 0")
+	}
+	if arc.lut[10].list != arc.t2 {
+		t.Errorf("key after ghost hit not in t2")
+	}
+}
+
+func TestTwoQueueEvictionPolicyOrder(t *testing.T) {
+	q := NewTypedTwoQueueEvictionPolicy[int](8) // kin 2, kout 4
+
+	for _, key := range []int{1, 2, 3} {
+		q.ItemAccessed(key)
+	}
+	// a1in is over its target, so its oldest key is evicted first and becomes a ghost.
+	if got := q.Evict(); got != 1 {
+		t.Fatalf("Evict: got %d, want 1", got)
+	}
+	// A ghost that is accessed again is promoted to am.
+	q.ItemAccessed(1)
+	if q.lut[1].list != q.am {
+		t.Fatalf("ghost not promoted to am")
+	}
+	// Repeated access while in a1in doesn't promote.
+	q.ItemAccessed(2)
+	if q.lut[2].list != q.a1in {
+		t.Fatalf("key promoted out of a1in")
+	}
+
+	// a1in is at its target, so am's least recently used key goes next.
+	if got := q.Evict(); got != 1 {
+		t.Fatalf("Evict: got %d, want 1", got)
+	}
+	// With am empty, a1in is drained.
+	for _, want := range []int{2, 3} {
+		if got := q.Evict(); got != want {
+			t.Fatalf("Evict: got %d, want %d", got, want)
+		}
+	}
+	if got := q.Evict(); got != 0 {
+		t.Errorf("Evict on empty policy: got %d, want 0", got)
+	}
+}
+
+// policyBytes returns the heap memory a policy holds while tracking n keys.
+func policyBytes(p tracePolicy, n int) int64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	policy := p.new(n)
+	for key := 0; key < n; key++ {
+		policy.ItemAccessed(key)
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(policy)
+	return int64(after.HeapAlloc) - int64(before.HeapAlloc)
+}
+
+// BenchmarkPolicyMemoryPerEntry reports the heap memory each policy holds
+// per tracked key. CLOCK and sampled LRU should hold less than LRU.
+func BenchmarkPolicyMemoryPerEntry(b *testing.B) {
+	const n = 100_000
+	for _, p := range tracePolicies {
+		b.Run(p.name, func(b *testing.B) {
+			var total int64
+			for i := 0; i < b.N; i++ {
+				total += policyBytes(p, n)
+			}
+			b.ReportMetric(float64(total)/float64(b.N)/n, "B/key")
+		})
+	}
+}
+
+// BenchmarkPolicyHitRatio replays a zipf trace through a cache with each
+// policy, reporting the hit ratio alongside the cost per access.
+func BenchmarkPolicyHitRatio(b *testing.B) {
+	const capacity = 1000
+	keys := zipfTrace(rand.New(rand.NewSource(1)), 100_000, 200_000)
+	for _, p := range tracePolicies {
+		b.Run(p.name, func(b *testing.B) {
+			var ratio float64
+			for i := 0; i < b.N; i++ {
+				ratio = replayTrace(b, capacity, p.new(capacity), keys)
+			}
+			b.ReportMetric(ratio, "hit-ratio")
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(keys)), "ns/access")
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// CacheConfig is needed to create a new cache.
+type CacheConfig struct {
+	// DefaultTTL is how long keys added with Put live.
+	// Default is zero, meaning keys never expire.
+	DefaultTTL time.Duration
+	// Clock returns the current time.
+	// Default is time.Now.
+	Clock func() time.Time
+	// JanitorInterval is how often a background goroutine removes expired
+	// keys. Without a janitor, expired keys are only removed when they are
+	// read or when their space is needed. Call Close to stop the janitor.
+	// Default is zero, meaning no janitor.
+	JanitorInterval time.Duration
+	// AsyncEvictions, if positive, reports evictions to the OnEvict hook
+	// from a background goroutine, in order, with room for this many
+	// before the queue grows. The queue has no bound: the hook may evict
+	// keys itself, and those must not wait for the hook to finish.
+	// Default is zero, meaning the hook is called synchronously.
+	AsyncEvictions int
+	// SnapshotCodec encodes keys and values in snapshots.
+	// Default is GobCodec.
+	SnapshotCodec Codec
+	// PrefixIndex keeps string keys in a radix tree so InvalidatePrefix
+	// doesn't have to scan every key, at the cost of memory and slower
+	// writes.
+	// Default is false.
+	PrefixIndex bool
+}
+
+// CacheOption is used to configure a new cache.
+type CacheOption func(*CacheConfig)
+
+// WithDefaultTTL sets how long keys added with Put live.
+func WithDefaultTTL(ttl time.Duration) CacheOption {
+	return func(c *CacheConfig) {
+		c.DefaultTTL = ttl
+	}
+}
+
+// WithClock sets the clock used to expire keys.
+func WithClock(clock func() time.Time) CacheOption {
+	return func(c *CacheConfig) {
+		c.Clock = clock
+	}
+}
+
+// WithJanitor starts a background goroutine that removes expired keys every interval.
+func WithJanitor(interval time.Duration) CacheOption {
+	return func(c *CacheConfig) {
+		c.JanitorInterval = interval
+	}
+}
+
+// WithAsyncEvictions reports evictions to the OnEvict hook from a background
+// goroutine, with room queued for buffer of them before the queue grows.
+func WithAsyncEvictions(buffer int) CacheOption {
+	return func(c *CacheConfig) {
+		c.AsyncEvictions = buffer
+	}
+}
+
+// WithSnapshotCodec sets how keys and values are encoded in snapshots.
+func WithSnapshotCodec(codec Codec) CacheOption {
+	return func(c *CacheConfig) {
+		c.SnapshotCodec = codec
+	}
+}
+
+// WithPrefixIndex indexes string keys by prefix for InvalidatePrefix.
+func WithPrefixIndex() CacheOption {
+	return func(c *CacheConfig) {
+		c.PrefixIndex = true
+	}
+}
+
+// Close stops the janitor, if any, and waits for queued evictions to be
+// reported. Evictions after Close are reported synchronously, as without
+// WithAsyncEvictions. It is safe to call more than once, but not from the
+// OnEvict hook.
+func (c *TypedCache[K, V]) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	if c.evictionsDone != nil {
+		<-c.evictionsDone
+	}
+	return nil
+}
+
+func (c *TypedCache[K, V]) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			c.removeExpiredLocked()
+			c.unlock()
+		}
+	}
+}
+
+// removeExpiredLocked removes every expired key and returns how many there were.
+// Keys are popped off the expiry heap in order, so the cost depends on the
+// number of expired keys rather than the size of the cache.
+func (c *TypedCache[K, V]) removeExpiredLocked() int {
+	now := c.config.Clock()
+	n := 0
+	for c.expiry.Len() > 0 && !c.expiry.items[0].at.After(now) {
+		c.removeLocked(c.expiry.items[0].key, EvictionExpired)
+		n++
+	}
+	return n
+}
+
+// expiryHeap is a min-heap of keys ordered by expiry time, with an index so a
+// key's entry can be updated or removed in O(log n).
+type expiryHeap[K comparable] struct {
+	items []*expiryItem[K]
+	lut   map[K]*expiryItem[K]
+}
+
+type expiryItem[K comparable] struct {
+	key      K
+	at       time.Time
+	loadTime time.Duration // How long the value took to load, if known.
+	index    int
+}
+
+func newExpiryHeap[K comparable]() *expiryHeap[K] {
+	return &expiryHeap[K]{lut: make(map[K]*expiryItem[K])}
+}
+
+func (h *expiryHeap[K]) set(key K, at time.Time) {
+	if item, ok := h.lut[key]; ok {
+		item.at = at
+		heap.Fix(h, item.index)
+		return
+	}
+
+	item := &expiryItem[K]{key: key, at: at}
+	h.lut[key] = item
+	heap.Push(h, item)
+}
+
+func (h *expiryHeap[K]) remove(key K) {
+	if item, ok := h.lut[key]; ok {
+		heap.Remove(h, item.index)
+		delete(h.lut, key)
+	}
+}
+
+func (h *expiryHeap[K]) Len() int { return len(h.items) }
+
+func (h *expiryHeap[K]) Less(i, j int) bool { return h.items[i].at.Before(h.items[j].at) }
+
+func (h *expiryHeap[K]) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *expiryHeap[K]) Push(x any) {
+	item := x.(*expiryItem[K])
+	item.index = len(h.items)
+	h.items = append(h.items, item)
+}
+
+func (h *expiryHeap[K]) Pop() any {
+	old := h.items
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	h.items = old[:n-1]
+	return item
+}
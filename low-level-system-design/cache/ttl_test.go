@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for deterministic tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1_700_000_000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTTLCache(capacity int, clock *fakeClock, opts ...CacheOption) *TypedCache[string, int] {
+	opts = append([]CacheOption{WithClock(clock.Now)}, opts...)
+	return NewTypedCache[string, int](NewTypedMemoryStorage[string, int](capacity), NewTypedLRUEvictionPolicy[string](), opts...)
+}
+
+func TestCacheTTLLazyExpiry(t *testing.T) {
+	testCases := []struct {
+		name       string
+		defaultTTL time.Duration
+		ttl        time.Duration // Passed to PutWithTTL; negative means use Put.
+		advance    time.Duration
+		wantHit    bool
+	}{
+		{"no ttl", 0, -1, time.Hour, true},
+		{"before expiry", 0, time.Minute, time.Minute - time.Second, true},
+		{"at expiry", 0, time.Minute, time.Minute, false},
+		{"after expiry", 0, time.Minute, time.Hour, false},
+		{"default ttl", time.Minute, -1, time.Minute, false},
+		{"default ttl not reached", time.Minute, -1, time.Second, true},
+		{"explicit ttl overrides default", time.Minute, time.Hour, time.Minute, true},
+		{"zero ttl never expires", time.Minute, 0, 24 * time.Hour, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			c := newTTLCache(10, clock, WithDefaultTTL(tc.defaultTTL))
+
+			var err error
+			if tc.ttl < 0 {
+				err = c.Put("k", 1)
+			} else {
+				err = c.PutWithTTL("k", 1, tc.ttl)
+			}
+			if err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+
+			clock.Advance(tc.advance)
+			_, err = c.Get("k")
+			if gotHit := err == nil; gotHit != tc.wantHit {
+				t.Fatalf("Get hit: got %v, want %v (err %v)", gotHit, tc.wantHit, err)
+			}
+			if !tc.wantHit {
+				if !errors.Is(err, ErrKeyNotFound) {
+					t.Errorf("Get: got %v, want %v", err, ErrKeyNotFound)
+				}
+				// The expired key was removed, not just hidden.
+				if _, ok := c.store.Get("k"); ok {
+					t.Error("expired key still in storage")
+				}
+				if c.expiry.Len() != 0 {
+					t.Errorf("expiry heap length: got %d, want 0", c.expiry.Len())
+				}
+			}
+		})
+	}
+}
+
+func TestCacheTTLOverwrite(t *testing.T) {
+	clock := newFakeClock()
+	c := newTTLCache(10, clock)
+
+	if err := c.PutWithTTL("k", 1, time.Minute); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	clock.Advance(50 * time.Second)
+	// Overwriting restarts the clock.
+	if err := c.PutWithTTL("k", 2, time.Minute); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	clock.Advance(50 * time.Second)
+	if got, err := c.Get("k"); err != nil || got != 2 {
+		t.Fatalf("Get: got %d, %v, want 2, nil", got, err)
+	}
+
+	// Overwriting without a TTL clears the expiry.
+	if err := c.PutWithTTL("k", 3, 0); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	clock.Advance(time.Hour)
+	if got, err := c.Get("k"); err != nil || got != 3 {
+		t.Errorf("Get: got %d, %v, want 3, nil", got, err)
+	}
+}
+
+func TestCacheTTLDeleteExpired(t *testing.T) {
+	clock := newFakeClock()
+	c := newTTLCache(10, clock)
+	got := recordEvictions(c)
+	c.PutWithTTL("a", 1, time.Second)
+	clock.Advance(time.Second)
+
+	if err := c.Delete("a"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Delete of an expired key: got %v, want ErrKeyNotFound", err)
+	}
+	if want := []evicted{{"a", 1, EvictionExpired}}; !reflect.DeepEqual(got(), want) {
+		t.Errorf("evictions: got %v, want %v", got(), want)
+	}
+}
+
+func TestCacheTTLReclaimsExpiredBeforeEvicting(t *testing.T) {
+	clock := newFakeClock()
+	c := newTTLCache(3, clock)
+
+	c.Put("lru", 1)
+	c.PutWithTTL("short", 2, time.Second)
+	c.Put("recent", 3)
+	clock.Advance(time.Minute)
+
+	// The cache is full; the expired key makes room instead of the LRU one.
+	if err := c.Put("new", 4); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	for _, key := range []string{"lru", "recent", "new"} {
+		if _, err := c.Get(key); err != nil {
+			t.Errorf("Get(%q) failed: %v", key, err)
+		}
+	}
+}
+
+func TestCacheTTLEvictionClearsExpiry(t *testing.T) {
+	clock := newFakeClock()
+	c := newTTLCache(1, clock)
+
+	c.PutWithTTL("a", 1, time.Minute)
+	c.Put("b", 2)
+	if c.expiry.Len() != 0 {
+		t.Errorf("expiry heap length after evicting a: got %d, want 0", c.expiry.Len())
+	}
+}
+
+func TestCacheJanitor(t *testing.T) {
+	clock := newFakeClock()
+	c := newTTLCache(100, clock, WithJanitor(time.Millisecond))
+	defer c.Close()
+
+	for i, key := range []string{"a", "b", "c"} {
+		if err := c.PutWithTTL(key, i, time.Duration(i+1)*time.Minute); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	c.Put("forever", 0)
+
+	clock.Advance(2 * time.Minute)
+	waitFor(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		_, aOK := c.store.Get("a")
+		_, bOK := c.store.Get("b")
+		return !aOK && !bOK
+	})
+
+	for _, key := range []string{"c", "forever"} {
+		if _, err := c.Get(key); err != nil {
+			t.Errorf("Get(%q) failed: %v", key, err)
+		}
+	}
+}
+
+func TestCacheCloseStopsJanitor(t *testing.T) {
+	clock := newFakeClock()
+	c := newTTLCache(10, clock, WithJanitor(time.Millisecond))
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("second Close failed: %v", err)
+	}
+
+	c.PutWithTTL("k", 1, time.Second)
+	clock.Advance(time.Minute)
+	time.Sleep(20 * time.Millisecond)
+
+	c.mu.Lock()
+	_, ok := c.store.Get("k")
+	c.mu.Unlock()
+	if !ok {
+		t.Error("expired key removed after Close")
+	}
+}
+
+func TestExpiryHeapOrder(t *testing.T) {
+	h := newExpiryHeap[string]()
+	base := time.Unix(0, 0)
+	h.set("c", base.Add(3*time.Second))
+	h.set("a", base.Add(1*time.Second))
+	h.set("b", base.Add(2*time.Second))
+	h.set("d", base.Add(4*time.Second))
+
+	h.set("d", base.Add(500*time.Millisecond)) // Move to the front.
+	h.remove("b")
+	h.remove("missing")
+
+	var got []string
+	for h.Len() > 0 {
+		got = append(got, h.items[0].key)
+		h.remove(h.items[0].key)
+	}
+	want := []string{"d", "a", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("order: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order: got %v, want %v", got, want)
+		}
+	}
+}
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
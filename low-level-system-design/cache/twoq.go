@@ -0,0 +1,135 @@
+package cache
+
+// TwoQueueEvictionPolicy tracks arbitrary keys.
+type TwoQueueEvictionPolicy = TypedTwoQueueEvictionPolicy[any]
+
+// TypedTwoQueueEvictionPolicy implements the full 2Q algorithm. New keys enter
+// the a1in FIFO and are evicted from it without being promoted, so a scan
+// passes through without disturbing am, the LRU of keys that have proven
+// themselves. Keys evicted from a1in are remembered in the a1out ghost FIFO;
+// only a key accessed again while it is a ghost is promoted to am.
+type TypedTwoQueueEvictionPolicy[K comparable] struct {
+	kin  int // Target size of a1in.
+	kout int // Maximum size of a1out.
+
+	a1in, a1out, am *TypedDoublyLinkedList[K]
+	lut             map[K]*listEntry[K]
+}
+
+func NewTwoQueueEvictionPolicy(capacity int) *TwoQueueEvictionPolicy {
+	return NewTypedTwoQueueEvictionPolicy[any](capacity)
+}
+
+// NewTypedTwoQueueEvictionPolicy creates a 2Q policy for a cache holding
+// capacity keys, using the paper's recommended 25% of the capacity for a1in and
+// 50% for a1out.
+func NewTypedTwoQueueEvictionPolicy[K comparable](capacity int) *TypedTwoQueueEvictionPolicy[K] {
+	return &TypedTwoQueueEvictionPolicy[K]{
+		kin:   max(capacity/4, 1),
+		kout:  max(capacity/2, 1),
+		a1in:  new(TypedDoublyLinkedList[K]),
+		a1out: new(TypedDoublyLinkedList[K]),
+		am:    new(TypedDoublyLinkedList[K]),
+		lut:   make(map[K]*listEntry[K]),
+	}
+}
+
+func (q *TypedTwoQueueEvictionPolicy[K]) ItemAccessed(item K) {
+	entry, ok := q.lut[item]
+	if !ok {
+		q.lut[item] = &listEntry[K]{node: q.a1in.AddItemToHead(item), list: q.a1in}
+		return
+	}
+
+	switch entry.list {
+	case q.a1in:
+		// Correlated references shortly after the first don't count.
+	case q.a1out, q.am:
+		entry.list.RemoveNode(entry.node)
+		q.am.AddToHead(entry.node)
+		entry.list = q.am
+	}
+}
+
+// Victim returns the key Evict would remove without removing it.
+func (q *TypedTwoQueueEvictionPolicy[K]) Victim() (K, bool) {
+	from := q.am
+	if q.evictFromA1in() {
+		from = q.a1in
+	}
+	if from.tail == nil {
+		var zero K
+		return zero, false
+	}
+	return from.tail.Val, true
+}
+
+// Evict returns the next resident key to evict, or the zero value if no keys
+// are resident.
+func (q *TypedTwoQueueEvictionPolicy[K]) Evict() K {
+	if q.evictFromA1in() {
+		node := q.a1in.RemoveTail()
+		q.a1out.AddToHead(node)
+		q.lut[node.Val].list = q.a1out
+		if q.a1out.size > q.kout {
+			delete(q.lut, q.a1out.RemoveTail().Val)
+		}
+		return node.Val
+	}
+
+	node := q.am.RemoveTail()
+	if node == nil {
+		var zero K
+		return zero
+	}
+	delete(q.lut, node.Val)
+	return node.Val
+}
+
+// Remove forgets item entirely, without remembering it as a ghost.
+func (q *TypedTwoQueueEvictionPolicy[K]) Remove(item K) {
+	if entry, ok := q.lut[item]; ok {
+		entry.list.RemoveNode(entry.node)
+		delete(q.lut, item)
+	}
+}
+
+func (q *TypedTwoQueueEvictionPolicy[K]) evictFromA1in() bool {
+	return q.a1in.size > q.kin || (q.am.size == 0 && q.a1in.size > 0)
+}
+
+// exportState lists a1in and then am, each least recently used first, along
+// with the ghosts in a1out.
+func (q *TypedTwoQueueEvictionPolicy[K]) exportState() policyState[K] {
+	var s policyState[K]
+	for i, list := range []*TypedDoublyLinkedList[K]{q.a1in, q.am} {
+		for node := list.tail; node != nil; node = node.Prev {
+			s.Keys = append(s.Keys, policyKey[K]{Key: node.Val, List: i})
+		}
+	}
+	for node := q.a1out.tail; node != nil; node = node.Prev {
+		s.Ghosts = append(s.Ghosts, policyKey[K]{Key: node.Val})
+	}
+	return s
+}
+
+func (q *TypedTwoQueueEvictionPolicy[K]) importState(s policyState[K]) {
+	resident := []*TypedDoublyLinkedList[K]{q.a1in, q.am}
+	for _, k := range s.Keys {
+		entry, ok := q.lut[k.Key]
+		if !ok {
+			continue
+		}
+		entry.list.RemoveNode(entry.node)
+		entry.list = stateList(resident, k.List)
+		entry.list.AddToHead(entry.node)
+	}
+	for _, k := range s.Ghosts {
+		if _, ok := q.lut[k.Key]; !ok {
+			q.lut[k.Key] = &listEntry[K]{node: q.a1out.AddItemToHead(k.Key), list: q.a1out}
+		}
+	}
+	for q.a1out.size > q.kout {
+		delete(q.lut, q.a1out.RemoveTail().Val)
+	}
+}
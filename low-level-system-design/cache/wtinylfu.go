@@ -0,0 +1,179 @@
+package cache
+
+// WTinyLFUEvictionPolicy tracks arbitrary keys.
+type WTinyLFUEvictionPolicy = TypedWTinyLFUEvictionPolicy[any]
+
+// TypedWTinyLFUEvictionPolicy implements W-TinyLFU. New keys enter a small LRU
+// window, 1% of the capacity, where a burst of keys can build up a frequency.
+// A key pushed out of the window competes with the main segment's victim, and
+// TinyLFU keeps whichever has been accessed more often, so one-hit wonders
+// don't displace popular keys.
+//
+// The main segment is a segmented LRU: keys start in probation and move to
+// protected, 80% of the main segment, when accessed again, and protected keys
+// that fall out go back to probation. The victim is probation's least recently
+// used key.
+//
+// The policy records every access in its own TinyLFU, so the cache doesn't
+// need an admission policy too.
+type TypedWTinyLFUEvictionPolicy[K comparable] struct {
+	filter        *TypedTinyLFU[K]
+	windowSize    int // Target size of window.
+	mainSize      int // Target size of probation and protected together.
+	protectedSize int // Maximum size of protected.
+
+	window, probation, protected *TypedDoublyLinkedList[K]
+	lut                          map[K]*listEntry[K]
+}
+
+func NewWTinyLFUEvictionPolicy(capacity int, opts ...TinyLFUOption) *WTinyLFUEvictionPolicy {
+	return NewTypedWTinyLFUEvictionPolicy[any](capacity, opts...)
+}
+
+// NewTypedWTinyLFUEvictionPolicy creates a W-TinyLFU policy for a cache holding
+// capacity keys. opts configure its TinyLFU.
+func NewTypedWTinyLFUEvictionPolicy[K comparable](capacity int, opts ...TinyLFUOption) *TypedWTinyLFUEvictionPolicy[K] {
+	windowSize := max(capacity/100, 1)
+	mainSize := max(capacity-windowSize, 1)
+	return &TypedWTinyLFUEvictionPolicy[K]{
+		filter:        NewTypedTinyLFU[K](capacity, opts...),
+		windowSize:    windowSize,
+		mainSize:      mainSize,
+		protectedSize: max(mainSize*4/5, 1),
+		window:        new(TypedDoublyLinkedList[K]),
+		probation:     new(TypedDoublyLinkedList[K]),
+		protected:     new(TypedDoublyLinkedList[K]),
+		lut:           make(map[K]*listEntry[K]),
+	}
+}
+
+func (w *TypedWTinyLFUEvictionPolicy[K]) ItemAccessed(item K) {
+	w.filter.Record(item)
+
+	entry, ok := w.lut[item]
+	if !ok {
+		w.lut[item] = &listEntry[K]{node: w.window.AddItemToHead(item), list: w.window}
+		return
+	}
+
+	entry.list.RemoveNode(entry.node)
+	switch entry.list {
+	case w.window, w.protected:
+		entry.list.AddToHead(entry.node)
+	case w.probation:
+		w.move(entry, w.protected)
+		if w.protected.size > w.protectedSize {
+			w.move(w.lut[w.protected.RemoveTail().Val], w.probation)
+		}
+	}
+}
+
+// Victim returns the key Evict would remove without removing it.
+func (w *TypedWTinyLFUEvictionPolicy[K]) Victim() (K, bool) {
+	w.fillMain()
+	if node, _ := w.victim(); node != nil {
+		return node.Val, true
+	}
+	var zero K
+	return zero, false
+}
+
+// Evict removes the loser of the window's candidate and the main segment's
+// victim and returns it, or the zero value if no keys are tracked. A winning
+// candidate moves to probation.
+func (w *TypedWTinyLFUEvictionPolicy[K]) Evict() K {
+	w.fillMain()
+	node, candidate := w.victim()
+	if node == nil {
+		var zero K
+		return zero
+	}
+
+	if candidate != nil && candidate != node {
+		w.window.RemoveNode(candidate)
+		w.move(w.lut[candidate.Val], w.probation)
+	}
+	w.lut[node.Val].list.RemoveNode(node)
+	delete(w.lut, node.Val)
+	return node.Val
+}
+
+func (w *TypedWTinyLFUEvictionPolicy[K]) Remove(item K) {
+	if entry, ok := w.lut[item]; ok {
+		entry.list.RemoveNode(entry.node)
+		delete(w.lut, item)
+	}
+}
+
+// fillMain moves keys over the window's target into the main segment while it
+// has room, which they don't have to compete for.
+func (w *TypedWTinyLFUEvictionPolicy[K]) fillMain() {
+	for w.window.size > w.windowSize && w.probation.size+w.protected.size < w.mainSize {
+		w.move(w.lut[w.window.RemoveTail().Val], w.probation)
+	}
+}
+
+// victim returns the node Evict would remove and, if the window is over its
+// target, the window's candidate for the main segment.
+func (w *TypedWTinyLFUEvictionPolicy[K]) victim() (node, candidate *TypedDoubleLinkNode[K]) {
+	main := w.probation.tail
+	if main == nil {
+		main = w.protected.tail
+	}
+	switch {
+	case main == nil:
+		return w.window.tail, nil
+	case w.window.size <= w.windowSize:
+		return main, nil
+	case w.filter.Admit(w.window.tail.Val, main.Val):
+		return main, w.window.tail
+	default:
+		return w.window.tail, w.window.tail
+	}
+}
+
+// move adds entry's node, already unlinked, to the head of list.
+func (w *TypedWTinyLFUEvictionPolicy[K]) move(entry *listEntry[K], list *TypedDoublyLinkedList[K]) {
+	list.AddToHead(entry.node)
+	entry.list = list
+}
+
+// exportState lists probation, the window and then protected, each least
+// recently used first, with each key's estimated access count.
+func (w *TypedWTinyLFUEvictionPolicy[K]) exportState() policyState[K] {
+	var s policyState[K]
+	for _, list := range []*TypedDoublyLinkedList[K]{w.probation, w.window, w.protected} {
+		for node := list.tail; node != nil; node = node.Prev {
+			k := policyKey[K]{Key: node.Val, Count: w.filter.Estimate(node.Val)}
+			switch list {
+			case w.probation:
+				k.List = 1
+			case w.protected:
+				k.List = 2
+			}
+			s.Keys = append(s.Keys, k)
+		}
+	}
+	return s
+}
+
+// importState moves each key to its list and records the accesses its count
+// is missing, up to what the sketch can hold.
+func (w *TypedWTinyLFUEvictionPolicy[K]) importState(s policyState[K]) {
+	lists := []*TypedDoublyLinkedList[K]{w.window, w.probation, w.protected}
+	for _, k := range s.Keys {
+		entry, ok := w.lut[k.Key]
+		if !ok {
+			continue
+		}
+		entry.list.RemoveNode(entry.node)
+		w.move(entry, stateList(lists, k.List))
+		// The cache recorded one access when it added the key.
+		for range min(k.Count, maxCount) - 1 {
+			w.filter.Record(k.Key)
+		}
+	}
+	for w.protected.size > w.protectedSize {
+		w.move(w.lut[w.protected.RemoveTail().Val], w.probation)
+	}
+}
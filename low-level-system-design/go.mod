@@ -2,4 +2,15 @@ module github.com/ahrav/bb-lld
 
 go 1.22.1
 
-require github.com/google/uuid v1.6.0
+require (
+	github.com/ahrav/BlueprintBazaar v0.0.0
+	github.com/google/uuid v1.6.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dchest/siphash v1.2.3 // indirect
+	github.com/spaolacci/murmur3 v1.1.0 // indirect
+)
+
+replace github.com/ahrav/BlueprintBazaar => ../implementations